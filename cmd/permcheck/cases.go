@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jcsawyer123/simple-go-api/internal/auth/aims"
+	"github.com/jcsawyer123/simple-go-api/internal/auth/permission"
+)
+
+// Expected case outcomes
+const (
+	expectAllow = "allow"
+	expectDeny  = "deny"
+)
+
+// Case is a permission check with its expected outcome
+type Case struct {
+	Line       int
+	Permission string
+	Expected   string
+	Account    string
+}
+
+// loadCases reads cases from a CSV file of permission,expected[,account] records
+// Cases without an account use defaultAccount
+func loadCases(path, defaultAccount string) ([]Case, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening cases: %w", err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	var cases []Case
+	var errs []error
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading cases %s: %w", path, err)
+		}
+
+		line, _ := r.FieldPos(0)
+		if len(cases) == 0 && len(errs) == 0 && strings.EqualFold(record[0], "permission") {
+			continue
+		}
+		if len(record) < 2 || len(record) > 3 {
+			errs = append(errs, fmt.Errorf("line %d: expected permission,expected[,account]", line))
+			continue
+		}
+
+		c := Case{
+			Line:       line,
+			Permission: strings.TrimSpace(record[0]),
+			Expected:   strings.ToLower(strings.TrimSpace(record[1])),
+			Account:    defaultAccount,
+		}
+		if len(record) == 3 && strings.TrimSpace(record[2]) != "" {
+			c.Account = strings.TrimSpace(record[2])
+		}
+		if c.Expected != expectAllow && c.Expected != expectDeny {
+			errs = append(errs, fmt.Errorf("line %d: expected outcome must be %q or %q, got %q", line, expectAllow, expectDeny, record[1]))
+			continue
+		}
+		cases = append(cases, c)
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid cases %s: %w", path, errors.Join(errs...))
+	}
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("no cases in %s", path)
+	}
+	return cases, nil
+}
+
+// runCases checks each case and prints the failures and a summary, reporting whether all passed
+func runCases(w io.Writer, parser permission.Parser, info *aims.TokenInfo, cases []Case) bool {
+	failed := 0
+	for _, c := range cases {
+		result := check(parser, info, c.Account, c.Permission)
+
+		got := expectDeny
+		if result.Allowed {
+			got = expectAllow
+		}
+		if result.Err != nil || got != c.Expected {
+			failed++
+			fmt.Fprintf(w, "FAIL line %d: expected %s, got %s\n", c.Line, c.Expected, result)
+			continue
+		}
+		fmt.Fprintf(w, "PASS line %d: %s\n", c.Line, result)
+	}
+
+	fmt.Fprintf(w, "%d passed, %d failed\n", len(cases)-failed, failed)
+	return failed == 0
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+	"github.com/jcsawyer123/simple-go-api/internal/auth/aims"
+	"github.com/jcsawyer123/simple-go-api/internal/auth/permission"
+)
+
+// Result is the outcome of checking one requirement
+type Result struct {
+	Permission string
+	Account    string
+	Allowed    bool
+
+	// Outcome is granted, denied or insufficient, or invalid if the requirement didn't parse
+	Outcome string
+
+	// Rule is the permission that decided the check, several comma separated if an all()
+	// was granted, and Roles the roles holding them
+	Rule  string
+	Roles []string
+
+	Err error
+}
+
+// check evaluates a requirement expression against the token's permissions, within account if set
+func check(parser permission.Parser, info *aims.TokenInfo, account, expr string) Result {
+	result := Result{Permission: expr, Account: account}
+
+	required, err := parser.ParseRequirement(expr)
+	if err != nil {
+		result.Outcome = "invalid"
+		result.Err = err
+		return result
+	}
+
+	permissions := info.Permissions()
+	if account != "" {
+		permissions = info.AccountPermissions(account)
+	}
+
+	// The same evaluation as permission.CheckRequirement, keeping the set to report the deciding rule
+	set := parser.Compile(permissions)
+	var rules []string
+	status := "allowed"
+
+	err = required.Check(set)
+	var authErr *auth.AuthError
+	switch {
+	case err == nil:
+		result.Allowed = true
+		result.Outcome = permission.OutcomeGranted
+		rules = permission.Matched(required, set)
+	case errors.Is(err, auth.ErrPermissionDenied):
+		result.Outcome = permission.OutcomeDenied
+		status = "denied"
+		if errors.As(err, &authErr) {
+			if denied, ok := authErr.Details["denied_permission"].(string); ok {
+				rules = []string{denied}
+			}
+		}
+	default:
+		result.Outcome = permission.OutcomeInsufficient
+	}
+
+	result.Rule = strings.Join(rules, ", ")
+	for _, rule := range rules {
+		result.Roles = append(result.Roles, rolesHolding(info, account, rule, status)...)
+	}
+	sort.Strings(result.Roles)
+	result.Roles = slices.Compact(result.Roles)
+	return result
+}
+
+// rolesHolding names the roles that hold a permission with the given status
+func rolesHolding(info *aims.TokenInfo, account, perm, status string) []string {
+	var roles []string
+	for i := range info.Roles {
+		role := &info.Roles[i]
+		if account != "" && !role.AppliesToAccount(account) {
+			continue
+		}
+		if role.Permissions[perm] == status {
+			name := role.Name
+			if name == "" {
+				name = role.ID
+			}
+			roles = append(roles, name)
+		}
+	}
+	return roles
+}
+
+// String formats the result as a single line, e.g.
+// ALLOW myservice:managed:update:x (granted by myservice:*:update:* from Updater)
+func (r Result) String() string {
+	verdict := "DENY "
+	if r.Allowed {
+		verdict = "ALLOW"
+	}
+
+	subject := r.Permission
+	if r.Account != "" {
+		subject += " in account " + r.Account
+	}
+
+	switch {
+	case r.Err != nil:
+		return fmt.Sprintf("%s %s (%s: %v)", verdict, subject, r.Outcome, r.Err)
+	case r.Rule == "" && r.Allowed:
+		return fmt.Sprintf("%s %s (%s: satisfied without a grant)", verdict, subject, r.Outcome)
+	case r.Rule == "":
+		return fmt.Sprintf("%s %s (%s: no matching permission)", verdict, subject, r.Outcome)
+	default:
+		roles := "no role"
+		if len(r.Roles) > 0 {
+			roles = strings.Join(r.Roles, ", ")
+		}
+		return fmt.Sprintf("%s %s (%s by %s from %s)", verdict, subject, r.Outcome, r.Rule, roles)
+	}
+}
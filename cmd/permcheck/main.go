@@ -0,0 +1,122 @@
+// Command permcheck evaluates AIMS permission checks offline against a token info document
+//
+// It runs the same checks the server runs, so role definitions can be tested before they are
+// published to AIMS. Each check is a permission or a requirement expression, see permission.Requirement:
+//
+//	permcheck -token token.json myservice:managed:update:x instigator:42:disable:account
+//	aims-token-info | permcheck -account 42 'any(myservice:managed:update:x, myservice:*:admin)'
+//	permcheck -token token.json -cases cases.csv
+//
+// The token file holds the JSON AIMS returns from token validation. In table mode each CSV
+// record is a case of permission, expected outcome ("allow" or "deny") and an optional
+// account; a header record starting with "permission" and lines starting with '#' are skipped.
+// Expressions with commas must be quoted, e.g. "all(a:b, c:d)",allow.
+//
+// Permissions may have at most -max-sections sections, which defaults to the server's
+// AUTH_PERMISSION_MAX_SECTIONS so checks parse the same way they do in the server.
+//
+// The exit status is 0 if every permission is allowed or every case passes, 1 otherwise,
+// and 2 if the input can't be read.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/jcsawyer123/simple-go-api/internal/auth/aims"
+	"github.com/jcsawyer123/simple-go-api/internal/auth/permission"
+)
+
+func main() {
+	tokenPath := flag.String("token", "-", "token info JSON file, - for stdin")
+	account := flag.String("account", "", "only honour roles bound to this account")
+	casesPath := flag.String("cases", "", "CSV file of permission,expected[,account] cases to check")
+	maxSections := flag.Int("max-sections", envInt("AUTH_PERMISSION_MAX_SECTIONS", permission.DefaultMaxSections),
+		"maximum sections in a permission, defaults to AUTH_PERMISSION_MAX_SECTIONS")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: permcheck [-token file] [-account id] requirement...")
+		fmt.Fprintln(flag.CommandLine.Output(), "       permcheck [-token file] -cases file")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if (*casesPath == "") == (flag.NArg() == 0) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	info, err := loadTokenInfo(*tokenPath)
+	if err != nil {
+		fatal(err)
+	}
+
+	parser := permission.Parser{MaxSections: *maxSections}
+
+	var ok bool
+	if *casesPath != "" {
+		cases, err := loadCases(*casesPath, *account)
+		if err != nil {
+			fatal(err)
+		}
+		ok = runCases(os.Stdout, parser, info, cases)
+	} else {
+		ok = runChecks(os.Stdout, parser, info, *account, flag.Args())
+	}
+
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// loadTokenInfo reads a token info document from a file, or stdin if path is "-"
+func loadTokenInfo(path string) (*aims.TokenInfo, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("opening token info: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var info aims.TokenInfo
+	if err := json.NewDecoder(r).Decode(&info); err != nil {
+		return nil, fmt.Errorf("parsing token info %s: %w", path, err)
+	}
+	return &info, nil
+}
+
+// runChecks checks and prints each requirement, reporting whether all were allowed
+func runChecks(w io.Writer, parser permission.Parser, info *aims.TokenInfo, account string, exprs []string) bool {
+	allAllowed := true
+	for _, expr := range exprs {
+		result := check(parser, info, account, expr)
+		fmt.Fprintln(w, result)
+		allAllowed = allAllowed && result.Allowed
+	}
+	return allAllowed
+}
+
+// envInt reads an integer environment variable, def if it is unset
+func envInt(name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		fatal(fmt.Errorf("invalid %s %q: %w", name, value, err))
+	}
+	return n
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "permcheck: %v\n", err)
+	os.Exit(2)
+}
@@ -0,0 +1,98 @@
+package audit
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/jcsawyer123/simple-go-api/internal/logger"
+	"github.com/jcsawyer123/simple-go-api/internal/metrics"
+)
+
+// AsyncSink buffers events and writes them to another sink in the background
+//
+// Write never blocks: when the buffer is full the event is dropped and counted, so a slow
+// or stuck sink can't hold up the request path
+type AsyncSink struct {
+	sink    Sink
+	events  chan Event
+	done    chan struct{}
+	once    sync.Once
+	mu      sync.RWMutex // Guards closed against concurrent Write and Close
+	closed  bool
+	dropped atomic.Uint64
+
+	droppedCounter metrics.Counter
+	failedCounter  metrics.Counter
+}
+
+// NewAsyncSink creates an async sink writing to sink through a buffer of bufferSize events
+func NewAsyncSink(sink Sink, bufferSize int) *AsyncSink {
+	s := &AsyncSink{
+		sink:           sink,
+		events:         make(chan Event, bufferSize),
+		done:           make(chan struct{}),
+		droppedCounter: metrics.CounterMetric("audit_events_dropped_total", nil),
+		failedCounter:  metrics.CounterMetric("audit_events_failed_total", nil),
+	}
+
+	go s.run()
+	return s
+}
+
+// Write queues an event, dropping it if the buffer is full
+func (s *AsyncSink) Write(e Event) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		s.drop()
+		return nil
+	}
+
+	select {
+	case s.events <- e:
+	default:
+		s.drop()
+	}
+	return nil
+}
+
+// Dropped returns the number of events dropped so far
+func (s *AsyncSink) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close writes out the buffered events and closes the underlying sink
+func (s *AsyncSink) Close() error {
+	s.once.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		close(s.events)
+		s.mu.Unlock()
+	})
+
+	<-s.done
+	return s.sink.Close()
+}
+
+// run writes queued events until the sink is closed
+func (s *AsyncSink) run() {
+	defer close(s.done)
+
+	for e := range s.events {
+		if err := s.sink.Write(e); err != nil {
+			s.failedCounter.Inc()
+			logger.Errorf("Writing audit event: %v", err)
+		}
+	}
+
+	if dropped := s.Dropped(); dropped > 0 {
+		logger.Warnf("Audit log dropped %d events", dropped)
+	}
+}
+
+// drop counts a dropped event
+func (s *AsyncSink) drop() {
+	s.dropped.Add(1)
+	s.droppedCounter.Inc()
+}
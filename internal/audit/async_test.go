@@ -0,0 +1,113 @@
+package audit
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingSink records events, blocking each write until released
+type blockingSink struct {
+	started chan struct{}
+	release chan struct{}
+
+	mu     sync.Mutex
+	events []Event
+	closed bool
+}
+
+func newBlockingSink() *blockingSink {
+	return &blockingSink{
+		started: make(chan struct{}, 1),
+		release: make(chan struct{}),
+	}
+}
+
+func (s *blockingSink) Write(e Event) error {
+	select {
+	case s.started <- struct{}{}:
+	default:
+	}
+	<-s.release
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+	return nil
+}
+
+func (s *blockingSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func TestAsyncSinkDropsWithoutBlocking(t *testing.T) {
+	sink := newBlockingSink()
+	s := NewAsyncSink(sink, 1)
+
+	// The first event is taken by the writer, which then blocks
+	s.Write(testEvent(0))
+	select {
+	case <-sink.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the first event to reach the sink")
+	}
+
+	// The second fills the buffer and the rest are dropped, all without waiting on the sink
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		for i := 1; i < 5; i++ {
+			s.Write(testEvent(i))
+		}
+	}()
+	select {
+	case <-written:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Write blocked on a stuck sink")
+	}
+
+	if dropped := s.Dropped(); dropped != 3 {
+		t.Errorf("expected 3 dropped events, got %d", dropped)
+	}
+
+	close(sink.release)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAsyncSinkCloseDrains(t *testing.T) {
+	sink := newBlockingSink()
+	close(sink.release)
+
+	s := NewAsyncSink(sink, 10)
+	for i := 0; i < 10; i++ {
+		s.Write(testEvent(i))
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sink.mu.Lock()
+	if len(sink.events) != 10 || !sink.closed {
+		t.Errorf("expected 10 events written before the sink closed, got %d (closed %v)", len(sink.events), sink.closed)
+	}
+	for i, e := range sink.events {
+		if e.Path != testEvent(i).Path {
+			t.Errorf("expected events in order, got %s at %d", e.Path, i)
+		}
+	}
+	sink.mu.Unlock()
+
+	// Events after Close are counted as dropped rather than panicking on the closed buffer
+	s.Write(testEvent(10))
+	if dropped := s.Dropped(); dropped != 1 {
+		t.Errorf("expected the late event to be dropped, got %d dropped", dropped)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
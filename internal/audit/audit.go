@@ -0,0 +1,93 @@
+package audit
+
+import (
+	"sync"
+	"time"
+)
+
+// Decision outcomes
+const (
+	OutcomeAllowed      = "allowed"
+	OutcomeDenied       = "denied"
+	OutcomeInsufficient = "insufficient"
+	OutcomeError        = "error"
+)
+
+// Event is a single authorization decision
+type Event struct {
+	Time               time.Time `json:"time"`
+	RequestID          string    `json:"request_id,omitempty"`
+	PrincipalID        string    `json:"principal_id,omitempty"`
+	PrincipalType      string    `json:"principal_type,omitempty"`
+	AuthMethod         string    `json:"auth_method,omitempty"`
+	Provider           string    `json:"provider,omitempty"`
+	AccountID          string    `json:"account_id,omitempty"`
+	Method             string    `json:"method"`
+	Route              string    `json:"route,omitempty"`
+	Path               string    `json:"path"`
+	RequiredPermission string    `json:"required_permission"`
+	MatchedPermission  string    `json:"matched_permission,omitempty"`
+	Outcome            string    `json:"outcome"`
+	Error              string    `json:"error,omitempty"`
+	Cached             bool      `json:"cached"`
+}
+
+// Sink receives authorization decisions
+type Sink interface {
+	// Write records an event
+	Write(e Event) error
+
+	// Close flushes and releases the sink
+	Close() error
+}
+
+// NullSink is a no-op implementation of the Sink interface
+type NullSink struct{}
+
+// Write is a no-op for the null sink
+func (NullSink) Write(Event) error { return nil }
+
+// Close is a no-op for the null sink
+func (NullSink) Close() error { return nil }
+
+// Global audit sink
+var (
+	globalMu   sync.RWMutex
+	globalSink Sink = NullSink{}
+)
+
+// InitGlobal sets the global audit sink
+func InitGlobal(sink Sink) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+
+	globalSink = sink
+}
+
+// Enabled reports whether decisions are being recorded
+// Callers can skip building events that would be discarded
+func Enabled() bool {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+
+	_, null := globalSink.(NullSink)
+	return !null
+}
+
+// Record writes an event to the global sink
+func Record(e Event) error {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+
+	return globalSink.Write(e)
+}
+
+// CloseGlobal closes the global sink and stops recording
+func CloseGlobal() error {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+
+	err := globalSink.Close()
+	globalSink = NullSink{}
+	return err
+}
@@ -0,0 +1,136 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testEvent returns an event identified by its path, /0, /1, ...
+func testEvent(n int) Event {
+	return Event{Method: "GET", Path: fmt.Sprintf("/%d", n), RequiredPermission: "svc:read:*"}
+}
+
+// readPaths returns the paths of the events logged in a file, in order
+func readPaths(t *testing.T, path string) []string {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var paths []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, e.Path)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return paths
+}
+
+func TestFileSinkRotates(t *testing.T) {
+	line, err := json.Marshal(testEvent(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Room for two events per file
+	path := filepath.Join(t.TempDir(), "audit.log")
+	s, err := NewFileSink(path, int64(2*(len(line)+1)), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 7; i++ {
+		if err := s.Write(testEvent(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		path:        {"/6"},
+		path + ".1": {"/4", "/5"},
+		path + ".2": {"/2", "/3"},
+	}
+	for file, paths := range want {
+		if got := readPaths(t, file); fmt.Sprint(got) != fmt.Sprint(paths) {
+			t.Errorf("%s: expected events %v, got %v", filepath.Base(file), paths, got)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 backups to be kept, got %v", err)
+	}
+}
+
+func TestFileSinkWithoutBackups(t *testing.T) {
+	line, err := json.Marshal(testEvent(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	s, err := NewFileSink(path, int64(len(line)+1), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := s.Write(testEvent(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readPaths(t, path); fmt.Sprint(got) != "[/2]" {
+		t.Errorf("expected the log to restart with the last event, got %v", got)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("expected no backups, got %v", err)
+	}
+}
+
+func TestFileSinkCountsExistingLog(t *testing.T) {
+	line, err := json.Marshal(testEvent(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	for i := 0; i < 2; i++ {
+		s, err := NewFileSink(path, int64(len(line)+1), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Write(testEvent(i)); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The reopened log was already full, so the second event starts a new one
+	if got := readPaths(t, path+".1"); fmt.Sprint(got) != "[/0]" {
+		t.Errorf("expected the first event to be rotated out, got %v", got)
+	}
+	if got := readPaths(t, path); fmt.Sprint(got) != "[/1]" {
+		t.Errorf("expected the second event in a new log, got %v", got)
+	}
+	if err := (&FileSink{}).Write(testEvent(0)); err == nil {
+		t.Error("expected a closed log to refuse writes")
+	}
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// AccountResolver extracts the target account ID from a request
+// An empty string means the request does not identify an account
+type AccountResolver func(r *http.Request) string
+
+// AccountFromURLParam resolves the account ID from a chi URL parameter
+func AccountFromURLParam(name string) AccountResolver {
+	return func(r *http.Request) string {
+		return chi.URLParam(r, name)
+	}
+}
+
+// AccountFromHeader resolves the account ID from a request header
+func AccountFromHeader(name string) AccountResolver {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// FirstAccount tries each resolver in order and returns the first non-empty account ID
+func FirstAccount(resolvers ...AccountResolver) AccountResolver {
+	return func(r *http.Request) string {
+		for _, resolve := range resolvers {
+			if accountID := resolve(r); accountID != "" {
+				return accountID
+			}
+		}
+		return ""
+	}
+}
@@ -1,40 +1,42 @@
 package aims
 
 import (
-	"sync"
-
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
 	"github.com/jcsawyer123/simple-go-api/internal/auth/cache"
 )
 
 // PermissionCache manages caching of AIMS-specific permissions
+// Entries are keyed on a keyed hash of the token, never the raw token
 type PermissionCache struct {
-	cache      *cache.MemoryCache
-	parsedPerm sync.Map // Cache for parsed permissions
+	cache  *cache.MemoryCache
+	hasher *auth.TokenHasher
+}
+
+// tokenEntry is the cached state for a single token
+type tokenEntry struct {
+	info        *TokenInfo        // Token info as returned by AIMS, nil if only permissions were stored
+	principal   *auth.Principal   // Principal built from the token info, nil if only permissions were stored
+	permissions map[string]string // Permissions merged across all roles
 }
 
 // NewPermissionCache creates a new AIMS permission cache
-func NewPermissionCache(cache *cache.MemoryCache) *PermissionCache {
+func NewPermissionCache(cache *cache.MemoryCache, hasher *auth.TokenHasher) *PermissionCache {
 	return &PermissionCache{
-		cache: cache,
+		cache:  cache,
+		hasher: hasher,
 	}
 }
 
 // GetPermissions retrieves permissions from cache if they exist
 func (pc *PermissionCache) GetPermissions(token string) (map[string]string, bool) {
-	value, found := pc.cache.Get(token)
+	entry, found := pc.getEntry(token)
 	if !found {
 		return nil, false
 	}
 
-	// Type assert to the expected type
-	permissions, ok := value.(map[string]string)
-	if !ok {
-		return nil, false
-	}
-
 	// Create a copy to prevent external modifications
-	permissionsCopy := make(map[string]string, len(permissions))
-	for k, v := range permissions {
+	permissionsCopy := make(map[string]string, len(entry.permissions))
+	for k, v := range entry.permissions {
 		permissionsCopy[k] = v
 	}
 
@@ -49,33 +51,70 @@ func (pc *PermissionCache) SetPermissions(token string, permissions map[string]s
 		permissionsCopy[k] = v
 	}
 
-	pc.cache.Set(token, permissionsCopy)
+	pc.cache.Set(pc.hasher.Key(token), &tokenEntry{permissions: permissionsCopy})
 }
 
-// GetParsedPermission retrieves a parsed permission from cache
-func (pc *PermissionCache) GetParsedPermission(perm string) (*Permission, bool) {
-	if val, ok := pc.parsedPerm.Load(perm); ok {
-		return val.(*Permission), true
+// GetTokenInfo retrieves the full token info from cache if it exists
+// The returned value is shared and must not be modified
+func (pc *PermissionCache) GetTokenInfo(token string) (*TokenInfo, bool) {
+	entry, found := pc.getEntry(token)
+	if !found || entry.info == nil {
+		return nil, false
 	}
-	return nil, false
+	return entry.info, true
 }
 
-// SetParsedPermission stores a parsed permission in cache
-func (pc *PermissionCache) SetParsedPermission(perm string, p *Permission) {
-	pc.parsedPerm.Store(perm, p)
+// GetPrincipal retrieves the principal built from the cached token info if it exists
+// The returned value is shared and must not be modified
+func (pc *PermissionCache) GetPrincipal(token string) (*auth.Principal, bool) {
+	entry, found := pc.getEntry(token)
+	if !found || entry.principal == nil {
+		return nil, false
+	}
+	return entry.principal, true
 }
 
-// GetOrParsePerm gets a permission from cache or parses it
-func (pc *PermissionCache) GetOrParsePerm(perm string) (*Permission, error) {
-	if p, ok := pc.GetParsedPermission(perm); ok {
-		return p, nil
+// GetPrincipalStale retrieves the cached principal, including one whose TTL has lapsed
+// but which is still within the cache's stale grace window. fresh reports whether
+// the entry is within its TTL.
+func (pc *PermissionCache) GetPrincipalStale(token string) (principal *auth.Principal, fresh bool, found bool) {
+	value, fresh, found := pc.cache.GetStale(pc.hasher.Key(token))
+	if !found {
+		return nil, false, false
 	}
 
-	p, err := ParsePermission(perm)
-	if err != nil {
-		return nil, err
+	entry, ok := value.(*tokenEntry)
+	if !ok || entry.principal == nil {
+		return nil, false, false
 	}
+	return entry.principal, fresh, true
+}
+
+// SetTokenInfo stores the token info, its principal and merged permissions in cache
+// and returns the principal
+func (pc *PermissionCache) SetTokenInfo(token string, info *TokenInfo) *auth.Principal {
+	principal := info.Principal()
+	pc.cache.Set(pc.hasher.Key(token), &tokenEntry{
+		info:        info,
+		principal:   principal,
+		permissions: principal.Permissions,
+	})
+	return principal
+}
+
+// Delete removes any cached state for a token
+func (pc *PermissionCache) Delete(token string) {
+	pc.cache.Delete(pc.hasher.Key(token))
+}
 
-	pc.SetParsedPermission(perm, p)
-	return p, nil
+// getEntry retrieves the cached entry for a token
+func (pc *PermissionCache) getEntry(token string) (*tokenEntry, bool) {
+	value, found := pc.cache.Get(pc.hasher.Key(token))
+	if !found {
+		return nil, false
+	}
+
+	// Type assert to the expected type
+	entry, ok := value.(*tokenEntry)
+	return entry, ok
 }
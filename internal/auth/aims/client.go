@@ -4,15 +4,19 @@ package aims
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"net/http"
 	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/jcsawyer123/simple-go-api/internal/auth"
 	"github.com/jcsawyer123/simple-go-api/internal/auth/cache"
+	"github.com/jcsawyer123/simple-go-api/internal/auth/permission"
 	"github.com/jcsawyer123/simple-go-api/internal/logger"
+	"github.com/jcsawyer123/simple-go-api/internal/metrics"
 	"github.com/sony/gobreaker"
+	"golang.org/x/sync/singleflight"
 )
 
 // Client implements the auth.Service interface for AIMS authentication
@@ -21,86 +25,224 @@ type Client struct {
 	client    *resty.Client
 	breaker   *gobreaker.CircuitBreaker
 	permCache *PermissionCache
+	enforcer  *permission.Enforcer // Checks permissions against the principals of tokens
+	hasher    *auth.TokenHasher    // Derives cache and lookup keys so raw tokens are never stored
+	rejected  *cache.MemoryCache   // Short-lived cache of tokens AIMS rejected
+	lookups   singleflight.Group   // Coalesces concurrent lookups of the same token
+	coalesced metrics.Counter      // Callers served by another caller's in-flight lookup
+	stale     metrics.Counter      // Lookups served from stale cache entries
+
+	rejectedHits   metrics.Counter // Lookups answered by the rejected token cache
+	rejectedStores metrics.Counter // Tokens added to the rejected token cache
 }
 
 // Ensure Client implements the auth.Service interface
 var _ auth.Service = (*Client)(nil)
 
-// NewClient creates a new AIMS auth client with default settings
-func NewClient(baseURL string) (*Client, error) {
-	// Create circuit breaker
-	cb := gobreaker.NewCircuitBreaker(gobreaker.Settings{
-		Name:        "aims-auth-service",
-		MaxRequests: 3,
-		Interval:    0,
-		Timeout:     10 * time.Second,
-		ReadyToTrip: func(counts gobreaker.Counts) bool {
-			failureRatio := float64(counts.TotalFailures) / float64(counts.Requests)
-			return counts.Requests >= 3 && failureRatio >= 0.6
-		},
-		OnStateChange: func(name string, from, to gobreaker.State) {
-			log.Printf("Circuit breaker %s state change: %s -> %s", name, from, to)
-		},
-	})
+// Option configures a Client
+type Option func(*clientOptions)
+
+// clientOptions holds the settings applied by Options
+type clientOptions struct {
+	config auth.BaseServiceConfig
+}
+
+// WithConfig sets the timeouts, retries, caching and circuit breaker thresholds used by the client
+func WithConfig(cfg auth.BaseServiceConfig) Option {
+	return func(o *clientOptions) {
+		o.config = cfg
+	}
+}
+
+// NewClient creates a new AIMS auth client
+// Required permissions are parsed through perms. Settings not supplied through options
+// default to auth.DefaultServiceConfig
+func NewClient(baseURL string, perms *permission.Cache, opts ...Option) (*Client, error) {
+	o := clientOptions{config: auth.DefaultServiceConfig()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	cfg := o.config
+
+	cb := auth.NewCircuitBreaker("aims-auth-service", cfg)
 
 	// Create HTTP client
 	client := resty.New().
-		SetTimeout(5 * time.Second).
-		SetRetryCount(3).
-		SetRetryWaitTime(100 * time.Millisecond).
-		SetRetryMaxWaitTime(2 * time.Second)
+		SetTimeout(cfg.Timeout).
+		SetRetryCount(cfg.RetryCount).
+		SetRetryWaitTime(cfg.RetryWaitTime).
+		SetRetryMaxWaitTime(cfg.RetryMaxWaitTime)
+
+	// Create cache, retaining expired entries for the grace window so they can be
+	// served while AIMS is unavailable
+	memCache := cache.NewMemoryCacheWithConfig(cache.Config{
+		TTL:             cfg.CacheTTL,
+		CleanupInterval: cfg.CacheTTL * 2,
+		StaleGrace:      cfg.CacheStaleGrace,
+	})
+	hasher, err := auth.NewTokenHasher([]byte(cfg.CacheKeySecret))
+	if err != nil {
+		return nil, err
+	}
+	permCache := NewPermissionCache(memCache, hasher)
 
-	// Create cache
-	memCache := cache.NewMemoryCache(5 * time.Minute)
-	permCache := NewPermissionCache(memCache)
+	// Create the rejected token cache, bounded so a flood of distinct bad tokens can't exhaust memory
+	rejectedEvictions := metrics.CounterMetric("aims_rejected_token_cache_evictions_total", nil)
+	rejected := cache.NewMemoryCacheWithConfig(cache.Config{
+		TTL:             cfg.RejectedCacheTTL,
+		CleanupInterval: cfg.RejectedCacheTTL * 2,
+		MaxEntries:      cfg.RejectedCacheMaxEntries,
+		OnEvict:         func(string) { rejectedEvictions.Inc() },
+	})
 
-	return &Client{
+	c := &Client{
 		baseURL:   baseURL,
 		client:    client,
 		breaker:   cb,
 		permCache: permCache,
-	}, nil
+		hasher:    hasher,
+		rejected:  rejected,
+		coalesced: metrics.CounterMetric("aims_token_lookups_coalesced_total", nil),
+		stale:     metrics.CounterMetric("aims_token_lookups_stale_total", nil),
+
+		rejectedHits:   metrics.CounterMetric("aims_rejected_token_cache_hits_total", nil),
+		rejectedStores: metrics.CounterMetric("aims_rejected_token_cache_stores_total", nil),
+	}
+	c.enforcer = permission.NewEnforcer(perms, c.requestPrincipal)
+
+	return c, nil
 }
 
-// ValidateToken validates a token against the AIMS auth service
-func (c *Client) ValidateToken(ctx context.Context, token string) error {
-	_, err := c.breaker.Execute(func() (interface{}, error) {
-		resp, err := c.client.R().
-			SetContext(ctx).
-			SetHeader(AimsHeaderName, token).
-			Get(c.baseURL + "/aims/v1/token_info")
+// ValidateToken validates a token against the AIMS auth service and returns its principal
+// Valid tokens are cached, so the same lookup also serves later permission checks
+func (c *Client) ValidateToken(ctx context.Context, token string) (*auth.Principal, error) {
+	principal, _, err := c.principal(ctx, token)
+	return principal, err
+}
 
-		if err != nil {
-			return nil, fmt.Errorf("aims auth request failed: %w", err)
+// ValidatePermissions checks if the token has the required permission
+// requiredPerm may be a requirement expression, see permission.Requirement
+func (c *Client) ValidatePermissions(ctx context.Context, token, requiredPerm string) error {
+	return c.enforcer.ValidatePermissions(ctx, token, requiredPerm)
+}
+
+// ValidateAccountPermissions checks if the token has the required permission within an account
+// Only roles bound to the account, or to all accounts, are taken into account
+func (c *Client) ValidateAccountPermissions(ctx context.Context, token, accountID, requiredPerm string) error {
+	return c.enforcer.ValidateAccountPermissions(ctx, token, accountID, requiredPerm)
+}
+
+// requestPrincipal returns the principal resolved when the request was authenticated,
+// falling back to a cache or AIMS lookup
+func (c *Client) requestPrincipal(ctx context.Context, token string) (*auth.Principal, error) {
+	if principal, ok := auth.PrincipalForToken(ctx, token, AuthMethod); ok {
+		return principal, nil
+	}
+
+	principal, _, err := c.principal(ctx, token)
+	return principal, err
+}
+
+// principal returns the principal for a token, checking the cache before calling AIMS
+//
+// Entries whose TTL has lapsed are served stale for the cache's grace window while
+// they are refreshed in the background, so a breaker trip or AIMS outage doesn't fail
+// tokens that were valid moments ago
+//
+// cached reports whether the principal was served from the cache rather than fetched from AIMS
+func (c *Client) principal(ctx context.Context, token string) (*auth.Principal, bool, error) {
+	// Check cache first
+	principal, fresh, exists := c.permCache.GetPrincipalStale(token)
+	if exists {
+		if !principal.ExpiresAt.IsZero() && time.Now().After(principal.ExpiresAt) {
+			c.permCache.Delete(token)
+			return nil, false, auth.NewAuthError(auth.ErrExpiredToken, "token expired", http.StatusUnauthorized)
 		}
 
-		if resp.StatusCode() != 200 {
-			return nil, fmt.Errorf("invalid token: status %d", resp.StatusCode())
+		if fresh {
+			logger.InfofWCtx(ctx, "token info hit cache")
+			return principal, true, nil
 		}
 
-		return resp, nil
-	})
+		c.stale.Inc()
+		logger.WarnfWCtx(ctx, "token info served stale from cache, refreshing in background (token %s)", c.hasher.Fingerprint(token))
+		c.refreshPrincipal(ctx, token)
+		return principal, true, nil
+	}
 
-	return err
+	// Recently rejected tokens fail without another AIMS round trip
+	if _, rejected := c.rejected.Get(c.hasher.Key(token)); rejected {
+		c.rejectedHits.Inc()
+		return nil, false, auth.NewAuthError(auth.ErrInvalidToken, "AIMS recently rejected the token", http.StatusUnauthorized)
+	}
+
+	logger.WarnfWCtx(ctx, "token info miss cache")
+
+	// Cache miss - fetch from auth service
+	principal, err := c.loadPrincipal(ctx, token)
+	return principal, false, err
 }
 
-// ValidatePermissions checks if the token has the required permission
-func (c *Client) ValidatePermissions(ctx context.Context, token, requiredPerm string) error {
-	requiredPermObj, err := c.permCache.GetOrParsePerm(requiredPerm)
-	if err != nil {
-		return fmt.Errorf("invalid required permission: %w", err)
+// loadPrincipal fetches and caches the principal for a token
+// Concurrent lookups of the same token share a single AIMS request
+func (c *Client) loadPrincipal(ctx context.Context, token string) (*auth.Principal, error) {
+	leader := false
+	result := c.lookups.DoChan(c.hasher.Key(token), func() (interface{}, error) {
+		leader = true
+
+		// Detach from the caller's cancellation so one waiter giving up doesn't fail the others
+		return c.fetchPrincipal(context.WithoutCancel(ctx), token)
+	})
+
+	select {
+	case res := <-result:
+		if !leader {
+			c.coalesced.Inc()
+			logger.DebugfWCtx(ctx, "token info lookup coalesced with in-flight request")
+		}
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*auth.Principal), nil
+	case <-ctx.Done():
+		return nil, auth.NewAuthError(fmt.Errorf("%w: %w", auth.ErrServiceUnavailable, ctx.Err()), "token lookup abandoned", http.StatusServiceUnavailable)
 	}
+}
 
-	// Check cache first
-	if permissions, exists := c.permCache.GetPermissions(token); exists {
-		logger.InfofWCtx(ctx, "permission check hit cache")
-		return CheckPermissions(requiredPermObj, permissions)
+// refreshPrincipal refreshes a cached principal in the background
+// The stale entry is kept if AIMS is unavailable and dropped if AIMS rejects the token
+func (c *Client) refreshPrincipal(ctx context.Context, token string) {
+	// The result channel is buffered, so it can be dropped without leaking the lookup
+	c.lookups.DoChan(c.hasher.Key(token), func() (interface{}, error) {
+		principal, err := c.fetchPrincipal(context.WithoutCancel(ctx), token)
+		if err != nil {
+			logger.WarnfWCtx(ctx, "background token info refresh failed (token %s): %v", c.hasher.Fingerprint(token), err)
+		}
+		return principal, err
+	})
+}
+
+// fetchPrincipal fetches the token info from AIMS and caches it
+func (c *Client) fetchPrincipal(ctx context.Context, token string) (*auth.Principal, error) {
+	tokenInfo, err := c.fetchTokenInfo(ctx, token)
+	if err != nil {
+		// Don't keep serving a stale entry for a token AIMS no longer accepts, and
+		// remember the rejection so repeated attempts don't reach AIMS. Transport
+		// failures and outages are never cached.
+		if errors.Is(err, auth.ErrInvalidToken) {
+			c.permCache.Delete(token)
+			c.rejected.Set(c.hasher.Key(token), struct{}{})
+			c.rejectedStores.Inc()
+		}
+		return nil, err
 	}
 
-	logger.WarnfWCtx(ctx, "permission check miss cache")
+	// Cache the token info so later requests and permission checks skip AIMS
+	return c.permCache.SetTokenInfo(token, tokenInfo), nil
+}
 
-	// Cache miss - fetch from auth service
-	var tokenInfo TokenInfo
+// fetchTokenInfo retrieves the token info for a token from the auth service
+func (c *Client) fetchTokenInfo(ctx context.Context, token string) (*TokenInfo, error) {
 	resp, err := c.breaker.Execute(func() (interface{}, error) {
 		resp, err := c.client.R().
 			SetContext(ctx).
@@ -108,40 +250,30 @@ func (c *Client) ValidatePermissions(ctx context.Context, token, requiredPerm st
 			Get(c.baseURL + "/aims/v1/token_info")
 
 		if err != nil {
-			return nil, fmt.Errorf("aims auth request failed: %w", err)
+			return nil, fmt.Errorf("%w: aims auth request failed: %w", auth.ErrServiceUnavailable, err)
 		}
 
-		if resp.StatusCode() != 200 {
-			return nil, fmt.Errorf("invalid token: status %d", resp.StatusCode())
+		switch resp.StatusCode() {
+		case http.StatusOK:
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return nil, fmt.Errorf("%w: status %d", auth.ErrInvalidToken, resp.StatusCode())
+		default:
+			return nil, fmt.Errorf("%w: status %d", auth.ErrServiceUnavailable, resp.StatusCode())
 		}
 
 		return resp.Body(), nil
 	})
 
 	if err != nil {
-		return fmt.Errorf("failed to validate token: %w", err)
+		return nil, lookupError(err)
 	}
 
+	var tokenInfo TokenInfo
 	if err := json.Unmarshal(resp.([]byte), &tokenInfo); err != nil {
-		return fmt.Errorf("failed to parse token info: %w", err)
+		return nil, lookupError(fmt.Errorf("failed to parse token info: %w", err))
 	}
 
-	// Combine all permissions from all roles
-	allPermissions := make(map[string]string)
-	for _, role := range tokenInfo.Roles {
-		for permStr, status := range role.Permissions {
-			// In case of conflicts, denied takes precedence
-			if existing, exists := allPermissions[permStr]; !exists || existing != "denied" {
-				allPermissions[permStr] = status
-			}
-		}
-	}
-
-	// Cache the permissions
-	c.permCache.SetPermissions(token, allPermissions)
-
-	// Check permissions with the combined set
-	return CheckPermissions(requiredPermObj, allPermissions)
+	return &tokenInfo, nil
 }
 
 // CreateMiddleware returns a middleware for this client
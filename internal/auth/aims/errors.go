@@ -0,0 +1,25 @@
+package aims
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+	"github.com/sony/gobreaker"
+)
+
+// lookupError maps a failed AIMS token lookup onto an auth.AuthError
+func lookupError(err error) error {
+	var authErr *auth.AuthError
+	switch {
+	case errors.As(err, &authErr):
+		return err
+	case errors.Is(err, auth.ErrInvalidToken):
+		return auth.NewAuthError(err, "AIMS rejected the token", http.StatusUnauthorized)
+	case errors.Is(err, gobreaker.ErrOpenState), errors.Is(err, gobreaker.ErrTooManyRequests):
+		return auth.NewAuthError(fmt.Errorf("%w: %w", auth.ErrServiceUnavailable, err), "AIMS circuit breaker open", http.StatusServiceUnavailable)
+	default:
+		return auth.NewAuthError(fmt.Errorf("%w: %w", auth.ErrServiceUnavailable, err), "AIMS unavailable", http.StatusServiceUnavailable)
+	}
+}
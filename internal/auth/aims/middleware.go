@@ -2,6 +2,7 @@
 package aims
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/jcsawyer123/simple-go-api/internal/auth"
@@ -9,55 +10,78 @@ import (
 
 // Middleware implements the auth.Middleware interface for AIMS
 type Middleware struct {
-	service *Client
+	service    *Client
+	extractors []auth.TokenExtractor
 }
 
-// Ensure Middleware implements the auth.Middleware interface
-var _ auth.Middleware = (*Middleware)(nil)
+// Ensure Middleware implements the auth.Provider interface
+var _ auth.Provider = (*Middleware)(nil)
+
+// MiddlewareOption configures a Middleware
+type MiddlewareOption func(*Middleware)
+
+// WithTokenExtractors sets the ordered list of locations the token is read from
+// The default is the x-aims-auth-token header
+func WithTokenExtractors(extractors ...auth.TokenExtractor) MiddlewareOption {
+	return func(m *Middleware) {
+		m.extractors = extractors
+	}
+}
 
 // NewMiddleware creates a new AIMS middleware
-func NewMiddleware(service *Client) *Middleware {
-	return &Middleware{
-		service: service,
+func NewMiddleware(service *Client, opts ...MiddlewareOption) *Middleware {
+	m := &Middleware{
+		service:    service,
+		extractors: []auth.TokenExtractor{auth.FromHeader(AimsHeaderName)},
 	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
 }
 
 // Authenticate implements the auth.Middleware interface
 func (m *Middleware) Authenticate(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token := r.Header.Get(AimsHeaderName)
-		if token == "" {
-			http.Error(w, "Unauthorized - No AIMS token provided", http.StatusUnauthorized)
-			return
-		}
+	return m.AuthenticateWith()(next)
+}
+
+// AuthenticateWith implements the auth.Middleware interface
+func (m *Middleware) AuthenticateWith(extractors ...auth.TokenExtractor) func(http.Handler) http.Handler {
+	return auth.AuthenticateWith(m, extractors)
+}
 
-		if err := m.service.ValidateToken(r.Context(), token); err != nil {
-			http.Error(w, "Unauthorized - Invalid AIMS token", http.StatusUnauthorized)
-			return
-		}
+// AuthenticateRequest implements the auth.Authenticator interface
+func (m *Middleware) AuthenticateRequest(r *http.Request, extractors []auth.TokenExtractor) (context.Context, error) {
+	if len(extractors) == 0 {
+		extractors = m.extractors
+	}
+
+	token := auth.ExtractToken(r, extractors)
+	if token == "" {
+		return nil, auth.NewAuthError(auth.ErrMissingToken, "no AIMS token provided", http.StatusUnauthorized)
+	}
+
+	principal, cached, err := m.service.principal(r.Context(), token)
+	if err != nil {
+		return nil, err
+	}
 
-		// Store the token in context for later use
-		ctx := auth.WithToken(r.Context(), token)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+	// Store the token and principal in context for later use
+	ctx := auth.WithToken(r.Context(), token)
+	ctx = auth.WithAuthCached(ctx, cached)
+	return auth.WithPrincipal(ctx, principal), nil
 }
 
 // RequirePermissions implements the auth.Middleware interface
+// requiredPerm may be a requirement expression over permission templates, see permission.Requirement
+// and permission.Permission.IsTemplate
 func (m *Middleware) RequirePermissions(requiredPerm string) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			token, ok := auth.TokenFromContext(r.Context())
-			if !ok {
-				http.Error(w, "Unauthorized - No token in context", http.StatusUnauthorized)
-				return
-			}
-
-			if err := m.service.ValidatePermissions(r.Context(), token, requiredPerm); err != nil {
-				http.Error(w, "Forbidden - Insufficient permissions", http.StatusForbidden)
-				return
-			}
-
-			next.ServeHTTP(w, r)
-		})
-	}
+	return m.service.enforcer.RequirePermissions(requiredPerm)
+}
+
+// RequireAccountPermissions implements the auth.Middleware interface
+// requiredPerm may be a requirement expression over permission templates, see permission.Requirement
+// and permission.Permission.IsTemplate
+func (m *Middleware) RequireAccountPermissions(requiredPerm string, resolve auth.AccountResolver) func(http.Handler) http.Handler {
+	return m.service.enforcer.RequireAccountPermissions(requiredPerm, resolve)
 }
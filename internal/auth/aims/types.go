@@ -1,8 +1,52 @@
 package aims
 
+import (
+	"time"
+
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+)
+
+// AuthMethod is the auth.Principal AuthMethod for principals authenticated by AIMS
+const AuthMethod = "aims"
+
+const (
+	// AimsHeaderName is the header name for AIMS tokens
+	AimsHeaderName = "x-aims-auth-token"
+
+	// AllAccountsID is the role account ID marking a role that applies to every account
+	AllAccountsID = auth.AllAccounts
+
+	// Common AIMS permission constants
+	MyServiceUpdatePerm          = "myservice:managed:update:*"
+	InstigatorDisableAccountPerm = "instigator:*:disable:account"
+
+	// Common AIMS permission templates, see permission.Permission.IsTemplate
+	MyServiceAccountUpdatePerm = "myservice:{accountID}:update:{resource}"
+
+	// Common AIMS requirement expressions, see permission.Requirement
+	MyServiceUpdateOrAdminPerm = "any(myservice:managed:update:*, myservice:*:admin)"
+)
+
 // TokenInfo represents the response from AIMS token validation
 type TokenInfo struct {
-	Roles []Role `json:"roles"`
+	User            User    `json:"user"`
+	Account         Account `json:"account"`
+	TokenExpiration int64   `json:"token_expiration"`
+	Roles           []Role  `json:"roles"`
+}
+
+// User represents the AIMS user a token was issued to
+type User struct {
+	ID        string `json:"id"`
+	AccountID string `json:"account_id"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+}
+
+// Account represents the AIMS account a token was issued for
+type Account struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
 }
 
 // Role represents an AIMS user role with associated permissions
@@ -15,3 +59,67 @@ type Role struct {
 	Created     map[string]interface{} `json:"created"`
 	Modified    map[string]interface{} `json:"modified"`
 }
+
+// AppliesToAccount reports whether the role grants permissions within the account
+func (r *Role) AppliesToAccount(accountID string) bool {
+	return r.AccountID == AllAccountsID || r.AccountID == accountID
+}
+
+// Permissions combines the permissions of all roles
+func (t *TokenInfo) Permissions() map[string]string {
+	return mergeRolePermissions(t.Roles, func(*Role) bool { return true })
+}
+
+// AccountPermissions combines the permissions of the roles bound to the account
+// or to all accounts
+func (t *TokenInfo) AccountPermissions(accountID string) map[string]string {
+	return mergeRolePermissions(t.Roles, func(r *Role) bool { return r.AppliesToAccount(accountID) })
+}
+
+// ExpiresAt returns the token expiry, zero if AIMS did not report one
+func (t *TokenInfo) ExpiresAt() time.Time {
+	if t.TokenExpiration == 0 {
+		return time.Time{}
+	}
+	return time.Unix(t.TokenExpiration, 0)
+}
+
+// Principal builds the authenticated principal described by the token info
+func (t *TokenInfo) Principal() *auth.Principal {
+	accountID := t.User.AccountID
+	if accountID == "" {
+		accountID = t.Account.ID
+	}
+
+	roles := make([]auth.PrincipalRole, len(t.Roles))
+	for i, role := range t.Roles {
+		roles[i] = auth.PrincipalRole{
+			ID:          role.ID,
+			Name:        role.Name,
+			AccountID:   role.AccountID,
+			Permissions: role.Permissions,
+		}
+	}
+
+	return &auth.Principal{
+		ID:          t.User.ID,
+		Type:        auth.PrincipalUser,
+		Name:        t.User.Name,
+		AccountID:   accountID,
+		Roles:       roles,
+		Permissions: t.Permissions(),
+		ExpiresAt:   t.ExpiresAt(),
+		AuthMethod:  AuthMethod,
+	}
+}
+
+// mergeRolePermissions combines the permissions of the roles accepted by include
+func mergeRolePermissions(roles []Role, include func(*Role) bool) map[string]string {
+	allPermissions := make(map[string]string)
+	for i := range roles {
+		if include(&roles[i]) {
+			auth.MergePermissions(allPermissions, roles[i].Permissions)
+		}
+	}
+	return allPermissions
+}
@@ -0,0 +1,12 @@
+package apikey
+
+import (
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+)
+
+// NewMiddleware creates a provider authenticating requests by API key
+// Keys are read from the x-api-key header unless auth.WithTokenExtractors is given
+func NewMiddleware(service *Service, opts ...auth.TokenProviderOption) *auth.TokenProvider {
+	defaults := []auth.TokenExtractor{auth.FromHeader(HeaderName)}
+	return auth.NewTokenProvider("API key", service.ValidateToken, service.enforcer, defaults, opts...)
+}
@@ -0,0 +1,64 @@
+package apikey
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+	"github.com/jcsawyer123/simple-go-api/internal/auth/permission"
+)
+
+// AuthMethod is the auth method recorded on principals authenticated by an API key
+const AuthMethod = "apikey"
+
+// HeaderName is the default header API keys are read from
+const HeaderName = "x-api-key"
+
+// Service implements the auth.Service interface for static API keys
+type Service struct {
+	store    *KeyStore
+	enforcer *permission.Enforcer
+}
+
+// Ensure Service implements the auth.Service interface
+var _ auth.Service = (*Service)(nil)
+
+// NewService creates an API key service backed by a key store
+// Required permissions are parsed through perms
+func NewService(store *KeyStore, perms *permission.Cache) *Service {
+	s := &Service{store: store}
+	s.enforcer = permission.NewEnforcer(perms, auth.RequestPrincipal(AuthMethod, s.ValidateToken))
+	return s
+}
+
+// ValidateToken validates an API key and returns its principal
+func (s *Service) ValidateToken(ctx context.Context, token string) (*auth.Principal, error) {
+	key, principal, ok := s.store.Lookup(token)
+	if !ok {
+		return nil, auth.NewAuthError(auth.ErrInvalidToken, "unknown API key", http.StatusUnauthorized)
+	}
+
+	if key.Expired(time.Now()) {
+		return nil, auth.NewAuthError(auth.ErrExpiredToken, "API key expired", http.StatusUnauthorized).
+			WithDetail("key_id", key.ID)
+	}
+
+	return principal, nil
+}
+
+// ValidatePermissions checks if the API key has the required permission
+// requiredPerm may be a requirement expression, see permission.Requirement
+func (s *Service) ValidatePermissions(ctx context.Context, token, requiredPerm string) error {
+	return s.enforcer.ValidatePermissions(ctx, token, requiredPerm)
+}
+
+// ValidateAccountPermissions checks if the API key has the required permission within an account
+func (s *Service) ValidateAccountPermissions(ctx context.Context, token, accountID, requiredPerm string) error {
+	return s.enforcer.ValidateAccountPermissions(ctx, token, accountID, requiredPerm)
+}
+
+// CreateMiddleware returns a middleware for this service
+func (s *Service) CreateMiddleware() auth.Middleware {
+	return NewMiddleware(s)
+}
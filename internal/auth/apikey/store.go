@@ -0,0 +1,242 @@
+package apikey
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+	"github.com/jcsawyer123/simple-go-api/internal/auth/permission"
+	"github.com/jcsawyer123/simple-go-api/internal/logger"
+)
+
+// KeyFile is the on-disk format of the key store
+//
+//	{
+//	  "keys": [
+//	    {
+//	      "id": "nightly-export",
+//	      "key_hash": "<hex SHA-256 of the key>",
+//	      "owner": "batch-export-job",
+//	      "account_id": "12345",
+//	      "permissions": ["myservice:*:read:*", "myservice:*:export:*"],
+//	      "expires_at": "2027-01-01T00:00:00Z"
+//	    }
+//	  ]
+//	}
+//
+// account_id may be "*" for keys valid in every account, and expires_at may be
+// omitted for keys that never expire
+type KeyFile struct {
+	Keys []Key `json:"keys"`
+}
+
+// Key is a single API key entry
+type Key struct {
+	ID          string    `json:"id"`
+	KeyHash     string    `json:"key_hash"`
+	Owner       string    `json:"owner"`
+	AccountID   string    `json:"account_id"`
+	Permissions []string  `json:"permissions"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// Expired reports whether the key has expired at the given time
+func (k *Key) Expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && now.After(k.ExpiresAt)
+}
+
+// HashKey returns the hex SHA-256 of an API key, as stored in the key file
+// Keys are random high-entropy secrets, so an unsalted hash is sufficient
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// storedKey is a loaded key with its principal built up front
+type storedKey struct {
+	key       Key
+	principal *auth.Principal
+}
+
+// KeyStore holds the API keys loaded from a key file, indexed by key hash
+type KeyStore struct {
+	path     string
+	parser   permission.Parser
+	mu       sync.RWMutex
+	keys     map[string]*storedKey
+	modTime  time.Time
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// NewKeyStore loads the key file at path, validating permissions with parser
+// The store is not reloaded until Watch is called
+func NewKeyStore(path string, parser permission.Parser) (*KeyStore, error) {
+	s := &KeyStore{
+		path:     path,
+		parser:   parser,
+		stopChan: make(chan struct{}),
+	}
+
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Lookup returns the key and principal for a raw API key
+func (s *KeyStore) Lookup(key string) (*Key, *auth.Principal, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored, ok := s.keys[HashKey(key)]
+	if !ok {
+		return nil, nil, false
+	}
+	return &stored.key, stored.principal, true
+}
+
+// Len returns the number of loaded keys
+func (s *KeyStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.keys)
+}
+
+// Reload re-reads the key file, replacing the loaded keys
+// An invalid file leaves the previously loaded keys in place
+func (s *KeyStore) Reload() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("reading API key file: %w", err)
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("reading API key file: %w", err)
+	}
+
+	keys, err := parseKeyFile(data, s.parser)
+	if err != nil {
+		return fmt.Errorf("parsing API key file %s: %w", s.path, err)
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.modTime = info.ModTime()
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Watch reloads the key file whenever its modification time changes, checking every interval
+// Reload failures are logged and the previous keys stay in use
+func (s *KeyStore) Watch(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stopChan:
+				return
+			case <-ticker.C:
+				s.reloadIfChanged()
+			}
+		}
+	}()
+}
+
+// Stop stops watching the key file
+func (s *KeyStore) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopChan)
+	})
+}
+
+// reloadIfChanged reloads the key file if it was modified since the last load
+func (s *KeyStore) reloadIfChanged() {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		logger.Warnf("Checking API key file: %v", err)
+		return
+	}
+
+	s.mu.RLock()
+	unchanged := info.ModTime().Equal(s.modTime)
+	s.mu.RUnlock()
+	if unchanged {
+		return
+	}
+
+	if err := s.Reload(); err != nil {
+		logger.Errorf("Reloading API keys, keeping previous keys: %v", err)
+		return
+	}
+	logger.Infof("Reloaded %d API keys from %s", s.Len(), s.path)
+}
+
+// parseKeyFile validates a key file and indexes its keys by hash
+func parseKeyFile(data []byte, parser permission.Parser) (map[string]*storedKey, error) {
+	var file KeyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*storedKey, len(file.Keys))
+	for i := range file.Keys {
+		key := file.Keys[i]
+		if key.ID == "" {
+			return nil, fmt.Errorf("key %d: missing id", i)
+		}
+
+		key.KeyHash = strings.ToLower(key.KeyHash)
+		if hash, err := hex.DecodeString(key.KeyHash); err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("key %s: key_hash must be a hex SHA-256", key.ID)
+		}
+		if _, exists := keys[key.KeyHash]; exists {
+			return nil, fmt.Errorf("key %s: duplicate key_hash", key.ID)
+		}
+
+		permissions := make(map[string]string, len(key.Permissions))
+		for _, permStr := range key.Permissions {
+			if _, err := parser.Parse(permStr); err != nil {
+				return nil, fmt.Errorf("key %s: %w", key.ID, err)
+			}
+			permissions[permStr] = "allowed"
+		}
+
+		keys[key.KeyHash] = &storedKey{
+			key:       key,
+			principal: keyPrincipal(&key, permissions),
+		}
+	}
+	return keys, nil
+}
+
+// keyPrincipal builds the principal authenticated by a key
+// The key's permissions form a single role bound to its account
+func keyPrincipal(key *Key, permissions map[string]string) *auth.Principal {
+	return &auth.Principal{
+		ID:        key.ID,
+		Type:      auth.PrincipalService,
+		Name:      key.Owner,
+		AccountID: key.AccountID,
+		Roles: []auth.PrincipalRole{{
+			ID:          key.ID,
+			Name:        key.Owner,
+			AccountID:   key.AccountID,
+			Permissions: permissions,
+		}},
+		Permissions: permissions,
+		ExpiresAt:   key.ExpiresAt,
+		AuthMethod:  AuthMethod,
+	}
+}
@@ -0,0 +1,181 @@
+package apikey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+	"github.com/jcsawyer123/simple-go-api/internal/auth/permission"
+)
+
+// testModTime is the modification time of the key files written by newTestStore
+var testModTime = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// writeKeyFile writes keys to path with the given modification time
+func writeKeyFile(t *testing.T, path string, modTime time.Time, keys ...Key) {
+	t.Helper()
+
+	data, err := json.Marshal(KeyFile{Keys: keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestStore loads a key store from a key file holding keys
+func newTestStore(t *testing.T, keys ...Key) (*KeyStore, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeKeyFile(t, path, testModTime, keys...)
+
+	store, err := NewKeyStore(path, permission.Parser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, path
+}
+
+func TestKeyStoreLookup(t *testing.T) {
+	store, _ := newTestStore(t, Key{
+		ID:          "export",
+		KeyHash:     strings.ToUpper(HashKey("secret")),
+		Owner:       "export-job",
+		AccountID:   "42",
+		Permissions: []string{"svc:read:*"},
+	})
+
+	key, principal, ok := store.Lookup("secret")
+	if !ok {
+		t.Fatal("expected the key to be found by its hash")
+	}
+	if key.ID != "export" || principal.ID != "export" || principal.AuthMethod != AuthMethod {
+		t.Errorf("unexpected key %+v and principal %+v", key, principal)
+	}
+	if got := principal.AccountPermissions("42"); got["svc:read:*"] != "allowed" {
+		t.Errorf("expected the permissions bound to account 42, got %v", got)
+	}
+	if got := principal.AccountPermissions("7"); len(got) != 0 {
+		t.Errorf("expected no permissions in another account, got %v", got)
+	}
+
+	if _, _, ok := store.Lookup(HashKey("secret")); ok {
+		t.Error("expected the stored hash not to work as a key")
+	}
+	if _, _, ok := store.Lookup("other"); ok {
+		t.Error("expected an unknown key not to be found")
+	}
+}
+
+func TestServiceValidateTokenExpiry(t *testing.T) {
+	store, _ := newTestStore(t,
+		Key{ID: "current", KeyHash: HashKey("current"), ExpiresAt: time.Now().Add(time.Hour)},
+		Key{ID: "expired", KeyHash: HashKey("expired"), ExpiresAt: time.Now().Add(-time.Hour)},
+		Key{ID: "forever", KeyHash: HashKey("forever")},
+	)
+	s := NewService(store, permission.NewCache(permission.Parser{}, 0))
+
+	tests := []struct {
+		token   string
+		wantErr error
+	}{
+		{"current", nil},
+		{"forever", nil},
+		{"expired", auth.ErrExpiredToken},
+		{"unknown", auth.ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			principal, err := s.ValidateToken(context.Background(), tt.token)
+			if tt.wantErr == nil {
+				if err != nil || principal.ID != tt.token {
+					t.Fatalf("expected key %s to be valid, got %v", tt.token, err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestKeyStoreReloadsOnModTime(t *testing.T) {
+	store, path := newTestStore(t, Key{ID: "old", KeyHash: HashKey("old")})
+
+	// Same modification time: the new contents aren't picked up
+	writeKeyFile(t, path, testModTime, Key{ID: "new", KeyHash: HashKey("new")})
+	store.reloadIfChanged()
+	if _, _, ok := store.Lookup("old"); !ok {
+		t.Fatal("expected an unchanged modification time to skip the reload")
+	}
+
+	writeKeyFile(t, path, testModTime.Add(time.Minute), Key{ID: "new", KeyHash: HashKey("new")})
+	store.reloadIfChanged()
+	if _, _, ok := store.Lookup("new"); !ok {
+		t.Fatal("expected a changed modification time to reload the keys")
+	}
+	if _, _, ok := store.Lookup("old"); ok {
+		t.Fatal("expected removed keys to be dropped on reload")
+	}
+
+	// An invalid file keeps the previous keys
+	writeKeyFile(t, path, testModTime.Add(2*time.Minute), Key{ID: "bad", KeyHash: "not-a-hash"})
+	store.reloadIfChanged()
+	if _, _, ok := store.Lookup("new"); !ok || store.Len() != 1 {
+		t.Fatal("expected an invalid key file to keep the previous keys")
+	}
+}
+
+func TestParseKeyFileValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    []Key
+		wantErr string
+	}{
+		{
+			name:    "missing id",
+			keys:    []Key{{KeyHash: HashKey("a")}},
+			wantErr: "missing id",
+		},
+		{
+			name:    "invalid hash",
+			keys:    []Key{{ID: "a", KeyHash: "abc"}},
+			wantErr: "key_hash must be a hex SHA-256",
+		},
+		{
+			name:    "duplicate hash",
+			keys:    []Key{{ID: "a", KeyHash: HashKey("a")}, {ID: "b", KeyHash: strings.ToUpper(HashKey("a"))}},
+			wantErr: "duplicate key_hash",
+		},
+		{
+			name:    "invalid permission",
+			keys:    []Key{{ID: "a", KeyHash: HashKey("a"), Permissions: []string{"svc:{read"}}},
+			wantErr: "key a:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(KeyFile{Keys: tt.keys})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := parseKeyFile(data, permission.Parser{}); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
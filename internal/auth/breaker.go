@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"errors"
+	"log"
+
+	"github.com/sony/gobreaker"
+)
+
+// NewCircuitBreaker creates the circuit breaker guarding calls to a remote auth service
+// A rejected token means the service is healthy, so ErrInvalidToken doesn't count as a failure
+func NewCircuitBreaker(name string, cfg BaseServiceConfig) *gobreaker.CircuitBreaker {
+	return gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:        name,
+		MaxRequests: cfg.CircuitBreakerMaxRequests,
+		Interval:    0,
+		Timeout:     cfg.CircuitBreakerTimeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			failureRatio := float64(counts.TotalFailures) / float64(counts.Requests)
+			return counts.Requests >= cfg.CircuitBreakerMinRequests && failureRatio >= cfg.CircuitBreakerFailureThreshold
+		},
+		IsSuccessful: func(err error) bool {
+			return err == nil || errors.Is(err, ErrInvalidToken)
+		},
+		OnStateChange: func(name string, from, to gobreaker.State) {
+			log.Printf("Circuit breaker %s state change: %s -> %s", name, from, to)
+		},
+	})
+}
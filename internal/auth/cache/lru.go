@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LRUCache is an in-memory cache bounded by entry count
+// When full, the least recently used entry is evicted to make room for a new one.
+// Entries do not expire.
+type LRUCache struct {
+	mu         sync.Mutex
+	items      map[string]*list.Element
+	order      *list.List // Most recently used at the front
+	maxEntries int
+	onEvict    func(key string)
+}
+
+type lruEntry struct {
+	key   string
+	value interface{}
+}
+
+// NewLRUCache creates an LRU cache holding at most maxEntries entries
+// onEvict, if set, is called when an entry is evicted to make room for a new one
+func NewLRUCache(maxEntries int, onEvict func(key string)) *LRUCache {
+	if maxEntries < 1 {
+		maxEntries = 1
+	}
+
+	return &LRUCache{
+		items:      make(map[string]*list.Element),
+		order:      list.New(),
+		maxEntries: maxEntries,
+		onEvict:    onEvict,
+	}
+}
+
+// Get retrieves a value from the cache, marking it as recently used
+func (c *LRUCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, found := c.items[key]
+	if !found {
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	return elem.Value.(*lruEntry).value, true
+}
+
+// Set stores a value in the cache, evicting the least recently used entry if the cache is full
+func (c *LRUCache) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, exists := c.items[key]; exists {
+		elem.Value.(*lruEntry).value = value
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	if len(c.items) >= c.maxEntries {
+		oldest := c.order.Remove(c.order.Back()).(*lruEntry)
+		delete(c.items, oldest.key)
+		if c.onEvict != nil {
+			c.onEvict(oldest.key)
+		}
+	}
+
+	c.items[key] = c.order.PushFront(&lruEntry{key: key, value: value})
+}
+
+// Len returns the number of entries held
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.items)
+}
+
+// Delete removes a key from the cache
+func (c *LRUCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, found := c.items[key]; found {
+		c.order.Remove(elem)
+		delete(c.items, key)
+	}
+}
+
+// Clear removes all items from the cache
+func (c *LRUCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+}
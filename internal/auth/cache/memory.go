@@ -7,11 +7,18 @@ import (
 )
 
 // MemoryCache is a simple in-memory cache
+//
+// Expired items are retained for a stale grace window, during which they are
+// only returned by GetStale
 type MemoryCache struct {
-	mu       sync.RWMutex
-	items    map[string]cacheItem
-	ttl      time.Duration
-	stopChan chan struct{}
+	mu              sync.RWMutex
+	items           map[string]cacheItem
+	ttl             time.Duration
+	staleGrace      time.Duration
+	cleanupInterval time.Duration
+	maxEntries      int
+	onEvict         func(key string)
+	stopChan        chan struct{}
 }
 
 type cacheItem struct {
@@ -21,10 +28,26 @@ type cacheItem struct {
 
 // NewMemoryCache creates a new in-memory cache with the specified TTL
 func NewMemoryCache(ttl time.Duration) *MemoryCache {
+	return NewMemoryCacheWithConfig(Config{
+		TTL:             ttl,
+		CleanupInterval: ttl * 2,
+	})
+}
+
+// NewMemoryCacheWithConfig creates a new in-memory cache from a Config
+func NewMemoryCacheWithConfig(cfg Config) *MemoryCache {
+	if cfg.CleanupInterval <= 0 {
+		cfg.CleanupInterval = DefaultConfig().CleanupInterval
+	}
+
 	mc := &MemoryCache{
-		items:    make(map[string]cacheItem),
-		ttl:      ttl,
-		stopChan: make(chan struct{}),
+		items:           make(map[string]cacheItem),
+		ttl:             cfg.TTL,
+		staleGrace:      cfg.StaleGrace,
+		cleanupInterval: cfg.CleanupInterval,
+		maxEntries:      cfg.MaxEntries,
+		onEvict:         cfg.OnEvict,
+		stopChan:        make(chan struct{}),
 	}
 
 	// Start cleanup goroutine
@@ -35,7 +58,7 @@ func NewMemoryCache(ttl time.Duration) *MemoryCache {
 
 // startCleanup periodically removes expired items from the cache
 func (mc *MemoryCache) startCleanup(ctx context.Context) {
-	ticker := time.NewTicker(mc.ttl * 2) // Clean up twice as often as TTL
+	ticker := time.NewTicker(mc.cleanupInterval)
 	defer ticker.Stop()
 
 	for {
@@ -49,7 +72,7 @@ func (mc *MemoryCache) startCleanup(ctx context.Context) {
 			now := time.Now()
 
 			for key, item := range mc.items {
-				if item.expiration.Before(now) {
+				if item.expiration.Add(mc.staleGrace).Before(now) {
 					delete(mc.items, key)
 				}
 			}
@@ -81,17 +104,81 @@ func (mc *MemoryCache) Get(key string) (interface{}, bool) {
 	return item.value, true
 }
 
+// GetStale retrieves a value from the cache, including expired values still within
+// the stale grace window. fresh reports whether the value is within its TTL.
+func (mc *MemoryCache) GetStale(key string) (value interface{}, fresh bool, found bool) {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	item, found := mc.items[key]
+	if !found {
+		return nil, false, false
+	}
+
+	now := time.Now()
+	if item.expiration.Add(mc.staleGrace).Before(now) {
+		return nil, false, false
+	}
+
+	return item.value, !item.expiration.Before(now), true
+}
+
 // Set stores a value in the cache with the default TTL
+// If the cache is bounded and full, an existing entry is evicted to make room
 func (mc *MemoryCache) Set(key string, value interface{}) {
 	mc.mu.Lock()
 	defer mc.mu.Unlock()
 
+	if _, exists := mc.items[key]; !exists && mc.maxEntries > 0 && len(mc.items) >= mc.maxEntries {
+		mc.evictOne()
+	}
+
 	mc.items[key] = cacheItem{
 		value:      value,
 		expiration: time.Now().Add(mc.ttl),
 	}
 }
 
+// evictOne removes an entry to make room for a new one, preferring an expired entry
+// among a small sample. Map iteration order is random, so the sample is too.
+// The caller must hold the write lock
+func (mc *MemoryCache) evictOne() {
+	const sampleSize = 8
+	now := time.Now()
+
+	var victim string
+	sampled := 0
+	for key, item := range mc.items {
+		if item.expiration.Add(mc.staleGrace).Before(now) {
+			delete(mc.items, key)
+			return
+		}
+		if sampled == 0 {
+			victim = key
+		}
+		if sampled++; sampled >= sampleSize {
+			break
+		}
+	}
+
+	if sampled == 0 {
+		return
+	}
+
+	delete(mc.items, victim)
+	if mc.onEvict != nil {
+		mc.onEvict(victim)
+	}
+}
+
+// Len returns the number of entries held, including expired entries not yet cleaned up
+func (mc *MemoryCache) Len() int {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	return len(mc.items)
+}
+
 // Delete removes a key from the cache
 func (mc *MemoryCache) Delete(key string) {
 	mc.mu.Lock()
@@ -27,6 +27,15 @@ type Config struct {
 
 	// CleanupInterval is how often the cache is checked for expired entries
 	CleanupInterval time.Duration
+
+	// StaleGrace is how long expired entries are retained to be served stale
+	StaleGrace time.Duration
+
+	// MaxEntries bounds the number of entries held, zero means unbounded
+	MaxEntries int
+
+	// OnEvict is called when an entry is evicted to make room for a new one
+	OnEvict func(key string)
 }
 
 // DefaultConfig returns a Config with sensible defaults
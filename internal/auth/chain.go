@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// Authenticator authenticates a request without writing a response, so that
+// several providers can be tried in turn
+type Authenticator interface {
+	// AuthenticateRequest verifies the request's credentials and returns a context
+	// carrying the principal. It returns an error wrapping ErrMissingToken when the
+	// request carries no credentials the provider understands. Non-empty extractors
+	// override the provider's configured token locations.
+	AuthenticateRequest(r *http.Request, extractors []TokenExtractor) (context.Context, error)
+}
+
+// Provider is an auth middleware that can take part in a Chain
+type Provider interface {
+	Middleware
+	Authenticator
+}
+
+// ChainLink is a named provider within a Chain
+type ChainLink struct {
+	Name     string
+	Provider Provider
+}
+
+// Chain authenticates requests by trying several providers in order
+//
+// The first provider to authenticate the request wins and its name is recorded in
+// the context; permission checks are delegated to that provider. Providers that
+// find no credentials they understand are skipped.
+type Chain struct {
+	links []ChainLink
+}
+
+// Ensure Chain implements the Provider interface
+// Chains can't be nested: the outer chain records its own link name over the inner
+// chain's, so the inner chain can't find the provider to delegate checks to
+var _ Provider = (*Chain)(nil)
+
+// NewChain creates a chain trying the providers in the given order
+func NewChain(links ...ChainLink) *Chain {
+	return &Chain{links: links}
+}
+
+// AuthenticateRequest implements the Authenticator interface
+// If no provider authenticates the request, the first failure other than a
+// missing token is returned
+func (c *Chain) AuthenticateRequest(r *http.Request, extractors []TokenExtractor) (context.Context, error) {
+	var firstErr error
+	for _, link := range c.links {
+		ctx, err := link.Provider.AuthenticateRequest(r, extractors)
+		if err == nil {
+			return WithAuthProvider(ctx, link.Name), nil
+		}
+
+		if firstErr == nil && !errors.Is(err, ErrMissingToken) {
+			firstErr = err
+		}
+	}
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return nil, NewAuthError(ErrMissingToken, "no credentials provided", http.StatusUnauthorized)
+}
+
+// Authenticate implements the Middleware interface
+func (c *Chain) Authenticate(next http.Handler) http.Handler {
+	return c.AuthenticateWith()(next)
+}
+
+// AuthenticateWith implements the Middleware interface
+func (c *Chain) AuthenticateWith(extractors ...TokenExtractor) func(http.Handler) http.Handler {
+	return AuthenticateWith(c, extractors)
+}
+
+// RequirePermissions implements the Middleware interface
+func (c *Chain) RequirePermissions(requiredPerm string) func(http.Handler) http.Handler {
+	return c.delegate(func(p Provider) func(http.Handler) http.Handler {
+		return p.RequirePermissions(requiredPerm)
+	})
+}
+
+// RequireAccountPermissions implements the Middleware interface
+func (c *Chain) RequireAccountPermissions(requiredPerm string, resolve AccountResolver) func(http.Handler) http.Handler {
+	return c.delegate(func(p Provider) func(http.Handler) http.Handler {
+		return p.RequireAccountPermissions(requiredPerm, resolve)
+	})
+}
+
+// delegate builds a middleware that dispatches to the provider that authenticated the request
+func (c *Chain) delegate(build func(Provider) func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		handlers := make(map[string]http.Handler, len(c.links))
+		for _, link := range c.links {
+			handlers[link.Name] = build(link.Provider)(next)
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name, _ := AuthProviderFromContext(r.Context())
+			handler, ok := handlers[name]
+			if !ok {
+				WriteError(w, r, NewAuthError(ErrMissingToken, "request not authenticated by this chain", http.StatusUnauthorized))
+				return
+			}
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
@@ -9,6 +9,15 @@ type ctxKey string
 const (
 	// TokenCtxKey is the context key for the authentication token
 	TokenCtxKey ctxKey = "auth-token"
+
+	// PrincipalCtxKey is the context key for the authenticated principal
+	PrincipalCtxKey ctxKey = "auth-principal"
+
+	// AuthProviderCtxKey is the context key for the name of the provider that authenticated the request
+	AuthProviderCtxKey ctxKey = "auth-provider"
+
+	// AuthCachedCtxKey is the context key for whether the principal was served from a cache
+	AuthCachedCtxKey ctxKey = "auth-cached"
 )
 
 // WithToken adds a token to the context
@@ -25,3 +34,48 @@ func TokenFromContext(ctx context.Context) (string, bool) {
 	token, ok := ctx.Value(TokenCtxKey).(string)
 	return token, ok
 }
+
+// WithPrincipal adds the authenticated principal to the context
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, PrincipalCtxKey, principal)
+}
+
+// PrincipalFromContext extracts the authenticated principal from the context
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+
+	principal, ok := ctx.Value(PrincipalCtxKey).(*Principal)
+	return principal, ok && principal != nil
+}
+
+// WithAuthProvider records the name of the provider that authenticated the request
+func WithAuthProvider(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, AuthProviderCtxKey, name)
+}
+
+// AuthProviderFromContext extracts the name of the provider that authenticated the request
+func AuthProviderFromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+
+	name, ok := ctx.Value(AuthProviderCtxKey).(string)
+	return name, ok
+}
+
+// WithAuthCached records whether the authenticated principal was served from a cache
+func WithAuthCached(ctx context.Context, cached bool) context.Context {
+	return context.WithValue(ctx, AuthCachedCtxKey, cached)
+}
+
+// AuthCachedFromContext reports whether the authenticated principal was served from a cache
+func AuthCachedFromContext(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+
+	cached, _ := ctx.Value(AuthCachedCtxKey).(bool)
+	return cached
+}
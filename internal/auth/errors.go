@@ -21,6 +21,12 @@ var (
 
 	// ErrPermissionDenied indicates a permission was explicitly denied
 	ErrPermissionDenied = errors.New("permission explicitly denied")
+
+	// ErrMissingToken indicates the request carried no token
+	ErrMissingToken = errors.New("missing token")
+
+	// ErrInvalidRequest indicates the request lacks information needed to authorize it
+	ErrInvalidRequest = errors.New("invalid auth request")
 )
 
 // AuthError represents an authentication or authorization error with additional context
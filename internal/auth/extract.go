@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// TokenExtractor extracts a token from a request
+// An empty string means the request does not carry a token in that location
+type TokenExtractor func(r *http.Request) string
+
+// queryTokensCtxKey is the context key for tokens stripped from the query string
+const queryTokensCtxKey ctxKey = "auth-query-tokens"
+
+// FromHeader extracts the token from a request header
+func FromHeader(name string) TokenExtractor {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// FromBearer extracts the token from an "Authorization: Bearer" header
+func FromBearer() TokenExtractor {
+	return func(r *http.Request) string {
+		scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") {
+			return ""
+		}
+		return strings.TrimSpace(token)
+	}
+}
+
+// FromCookie extracts the token from a named cookie
+func FromCookie(name string) TokenExtractor {
+	return func(r *http.Request) string {
+		cookie, err := r.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return cookie.Value
+	}
+}
+
+// FromQuery extracts the token from a query parameter
+//
+// Only parameters stripped from the URL by RedactQueryTokens are visible, so a
+// query token can never reach access logs: if the parameter isn't redacted, no
+// token is extracted. Callers must therefore build FromQuery and RedactQueryTokens
+// from the same parameter names, or the extractor silently finds nothing.
+func FromQuery(name string) TokenExtractor {
+	return func(r *http.Request) string {
+		tokens, ok := r.Context().Value(queryTokensCtxKey).(url.Values)
+		if !ok {
+			return ""
+		}
+		return tokens.Get(name)
+	}
+}
+
+// ExtractToken tries each extractor in order and returns the first token found
+func ExtractToken(r *http.Request, extractors []TokenExtractor) string {
+	for _, extract := range extractors {
+		if token := extract(r); token != "" {
+			return token
+		}
+	}
+	return ""
+}
+
+// ParseTokenExtractors parses a comma separated list of token sources, e.g.
+// "header:x-aims-auth-token,bearer,cookie:aims_token,query:token"
+func ParseTokenExtractors(spec string) ([]TokenExtractor, error) {
+	var extractors []TokenExtractor
+	for _, source := range strings.Split(spec, ",") {
+		source = strings.TrimSpace(source)
+		if source == "" {
+			continue
+		}
+
+		kind, name, _ := strings.Cut(source, ":")
+		if kind != "bearer" && name == "" {
+			return nil, fmt.Errorf("invalid token source (missing name): %s", source)
+		}
+
+		switch kind {
+		case "header":
+			extractors = append(extractors, FromHeader(name))
+		case "bearer":
+			extractors = append(extractors, FromBearer())
+		case "cookie":
+			extractors = append(extractors, FromCookie(name))
+		case "query":
+			extractors = append(extractors, FromQuery(name))
+		default:
+			return nil, fmt.Errorf("invalid token source (unknown kind %q): %s", kind, source)
+		}
+	}
+
+	if len(extractors) == 0 {
+		return nil, fmt.Errorf("invalid token sources (empty): %q", spec)
+	}
+	return extractors, nil
+}
+
+// RedactQueryTokens is a middleware that strips the named query parameters from the
+// request URL before it reaches logging middleware, keeping their values in the
+// context for FromQuery. It must run before any middleware that logs the URL.
+func RedactQueryTokens(names ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.RawQuery == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			query := r.URL.Query()
+			tokens := make(url.Values)
+			for _, name := range names {
+				if values, ok := query[name]; ok {
+					tokens[name] = values
+					query.Del(name)
+				}
+			}
+
+			if len(tokens) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Copy the URL so the original request is left untouched
+			u := *r.URL
+			u.RawQuery = query.Encode()
+
+			r = r.WithContext(context.WithValue(r.Context(), queryTokensCtxKey, tokens))
+			r.URL = &u
+			r.RequestURI = u.RequestURI()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package introspection
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+	"github.com/jcsawyer123/simple-go-api/internal/auth/cache"
+	"github.com/jcsawyer123/simple-go-api/internal/auth/permission"
+	"github.com/jcsawyer123/simple-go-api/internal/logger"
+	"github.com/sony/gobreaker"
+	"golang.org/x/sync/singleflight"
+)
+
+// Client implements the auth.Service interface for OAuth2 token introspection (RFC 7662)
+type Client struct {
+	endpoint     string
+	clientID     string
+	clientSecret string
+	client       *resty.Client
+	breaker      *gobreaker.CircuitBreaker
+	cache        *cache.MemoryCache
+	hasher       *auth.TokenHasher // Derives cache and lookup keys so raw tokens are never stored
+	lookups      singleflight.Group
+	scopes       ScopeMap
+	enforcer     *permission.Enforcer
+}
+
+// Ensure Client implements the auth.Service interface
+var _ auth.Service = (*Client)(nil)
+
+// Option configures a Client
+type Option func(*clientOptions)
+
+// clientOptions holds the settings applied by Options
+type clientOptions struct {
+	config auth.BaseServiceConfig
+	scopes ScopeMap
+}
+
+// WithConfig sets the timeouts, retries, caching and circuit breaker thresholds used by the client
+func WithConfig(cfg auth.BaseServiceConfig) Option {
+	return func(o *clientOptions) {
+		o.config = cfg
+	}
+}
+
+// WithScopeMap sets the mapping of scopes onto permissions
+func WithScopeMap(scopes ScopeMap) Option {
+	return func(o *clientOptions) {
+		o.scopes = scopes
+	}
+}
+
+// NewClient creates a new introspection client authenticating to the endpoint with client credentials
+// Required permissions are parsed through perms. Settings not supplied through options
+// default to auth.DefaultServiceConfig
+func NewClient(endpoint, clientID, clientSecret string, perms *permission.Cache, opts ...Option) (*Client, error) {
+	o := clientOptions{config: auth.DefaultServiceConfig()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	cfg := o.config
+
+	cb := auth.NewCircuitBreaker("oauth2-introspection", cfg)
+
+	// Create HTTP client
+	client := resty.New().
+		SetTimeout(cfg.Timeout).
+		SetRetryCount(cfg.RetryCount).
+		SetRetryWaitTime(cfg.RetryWaitTime).
+		SetRetryMaxWaitTime(cfg.RetryMaxWaitTime)
+
+	hasher, err := auth.NewTokenHasher([]byte(cfg.CacheKeySecret))
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		endpoint:     endpoint,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		client:       client,
+		breaker:      cb,
+		cache:        cache.NewMemoryCache(cfg.CacheTTL),
+		hasher:       hasher,
+		scopes:       o.scopes,
+	}
+	c.enforcer = permission.NewEnforcer(perms, auth.RequestPrincipal(AuthMethod, c.ValidateToken))
+
+	return c, nil
+}
+
+// ValidateToken introspects a token and returns its principal
+// Active tokens are cached for the cache TTL or until they expire, whichever is sooner
+func (c *Client) ValidateToken(ctx context.Context, token string) (*auth.Principal, error) {
+	key := c.hasher.Key(token)
+
+	if value, found := c.cache.Get(key); found {
+		principal := value.(*auth.Principal)
+		if principal.ExpiresAt.IsZero() || time.Now().Before(principal.ExpiresAt) {
+			return principal, nil
+		}
+
+		c.cache.Delete(key)
+		return nil, auth.NewAuthError(auth.ErrExpiredToken, "token expired", http.StatusUnauthorized)
+	}
+
+	// Concurrent lookups of the same token share a single introspection request
+	result := c.lookups.DoChan(key, func() (interface{}, error) {
+		principal, err := c.introspect(context.WithoutCancel(ctx), token)
+		if err != nil {
+			return nil, err
+		}
+
+		c.cache.Set(key, principal)
+		return principal, nil
+	})
+
+	select {
+	case res := <-result:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*auth.Principal), nil
+	case <-ctx.Done():
+		return nil, auth.NewAuthError(fmt.Errorf("%w: %w", auth.ErrServiceUnavailable, ctx.Err()), "token introspection abandoned", http.StatusServiceUnavailable)
+	}
+}
+
+// introspect calls the introspection endpoint and builds the principal of an active token
+func (c *Client) introspect(ctx context.Context, token string) (*auth.Principal, error) {
+	resp, err := c.breaker.Execute(func() (interface{}, error) {
+		resp, err := c.client.R().
+			SetContext(ctx).
+			SetBasicAuth(c.clientID, c.clientSecret).
+			SetHeader("Accept", "application/json").
+			SetFormData(map[string]string{
+				"token":           token,
+				"token_type_hint": "access_token",
+			}).
+			Post(c.endpoint)
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: introspection request failed: %w", auth.ErrServiceUnavailable, err)
+		}
+
+		// A 401 or 403 here rejects our client credentials, not the caller's token
+		if resp.StatusCode() != http.StatusOK {
+			return nil, fmt.Errorf("%w: status %d", auth.ErrServiceUnavailable, resp.StatusCode())
+		}
+
+		var introspection Response
+		if err := json.Unmarshal(resp.Body(), &introspection); err != nil {
+			return nil, fmt.Errorf("%w: failed to parse introspection response: %w", auth.ErrServiceUnavailable, err)
+		}
+		if !introspection.Active {
+			return nil, fmt.Errorf("%w: token inactive", auth.ErrInvalidToken)
+		}
+
+		return &introspection, nil
+	})
+
+	if err != nil {
+		return nil, introspectionError(err)
+	}
+
+	introspection := resp.(*Response)
+	if introspection.Expiry().IsZero() {
+		logger.DebugfWCtx(ctx, "introspection response has no exp (token %s)", c.hasher.Fingerprint(token))
+	}
+	return introspection.Principal(c.scopes), nil
+}
+
+// introspectionError maps an introspection failure onto an auth.AuthError
+func introspectionError(err error) error {
+	switch {
+	case errors.Is(err, auth.ErrInvalidToken):
+		return auth.NewAuthError(err, "token is not active", http.StatusUnauthorized)
+	case errors.Is(err, gobreaker.ErrOpenState), errors.Is(err, gobreaker.ErrTooManyRequests):
+		return auth.NewAuthError(fmt.Errorf("%w: %w", auth.ErrServiceUnavailable, err), "introspection circuit breaker open", http.StatusServiceUnavailable)
+	default:
+		return auth.NewAuthError(err, "introspection endpoint unavailable", http.StatusServiceUnavailable)
+	}
+}
+
+// ValidatePermissions checks if the token's scopes grant the required permission
+// requiredPerm may be a requirement expression, see permission.Requirement
+func (c *Client) ValidatePermissions(ctx context.Context, token, requiredPerm string) error {
+	return c.enforcer.ValidatePermissions(ctx, token, requiredPerm)
+}
+
+// ValidateAccountPermissions checks if the token's scopes grant the required permission within an account
+func (c *Client) ValidateAccountPermissions(ctx context.Context, token, accountID, requiredPerm string) error {
+	return c.enforcer.ValidateAccountPermissions(ctx, token, accountID, requiredPerm)
+}
+
+// CreateMiddleware returns a middleware for this client
+func (c *Client) CreateMiddleware() auth.Middleware {
+	return NewMiddleware(c)
+}
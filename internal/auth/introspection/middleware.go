@@ -0,0 +1,12 @@
+package introspection
+
+import (
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+)
+
+// NewMiddleware creates a provider authenticating requests by introspecting their access token
+// Tokens are read from the Authorization bearer token unless auth.WithTokenExtractors is given
+func NewMiddleware(client *Client, opts ...auth.TokenProviderOption) *auth.TokenProvider {
+	defaults := []auth.TokenExtractor{auth.FromBearer()}
+	return auth.NewTokenProvider("access token", client.ValidateToken, client.enforcer, defaults, opts...)
+}
@@ -0,0 +1,38 @@
+package introspection
+
+import (
+	"testing"
+)
+
+func TestScopeMapPermissions(t *testing.T) {
+	scopes := ScopeMap{
+		"users.read": {"iam:read:users", "iam:list:users"},
+		"admin":      {"iam:*"},
+	}
+
+	tests := []struct {
+		name   string
+		scopes []string
+		want   []string
+	}{
+		{name: "mapped", scopes: []string{"users.read"}, want: []string{"iam:read:users", "iam:list:users"}},
+		{name: "several", scopes: []string{"users.read", "admin"}, want: []string{"iam:read:users", "iam:list:users", "iam:*"}},
+		{name: "unmapped wildcard", scopes: []string{"*"}},
+		{name: "unmapped permission", scopes: []string{"iam:delete:users"}},
+		{name: "none"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := scopes.Permissions(tt.scopes)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for _, perm := range tt.want {
+				if got[perm] != "allowed" {
+					t.Errorf("expected %s to be allowed, got %v", perm, got)
+				}
+			}
+		})
+	}
+}
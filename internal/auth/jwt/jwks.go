@@ -0,0 +1,286 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+	"github.com/jcsawyer123/simple-go-api/internal/logger"
+	"golang.org/x/sync/singleflight"
+)
+
+// KeySource loads a raw JWKS document
+type KeySource func(ctx context.Context) ([]byte, error)
+
+// URLSource fetches the JWKS document from a URL
+func URLSource(url string, timeout time.Duration) KeySource {
+	client := resty.New().
+		SetTimeout(timeout).
+		SetRetryCount(2)
+
+	return func(ctx context.Context) ([]byte, error) {
+		resp, err := client.R().SetContext(ctx).Get(url)
+		if err != nil {
+			return nil, fmt.Errorf("fetching JWKS: %w", err)
+		}
+		if resp.StatusCode() != http.StatusOK {
+			return nil, fmt.Errorf("fetching JWKS: status %d", resp.StatusCode())
+		}
+		return resp.Body(), nil
+	}
+}
+
+// FileSource reads the JWKS document from a file
+func FileSource(path string) KeySource {
+	return func(ctx context.Context) ([]byte, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading JWKS: %w", err)
+		}
+		return data, nil
+	}
+}
+
+// KeySet is a cached set of verification keys loaded from a JWKS document
+//
+// The set is reloaded once it is older than the refresh interval, and when a token
+// names a kid the set doesn't hold. Reloads happen at most once per minimum refresh
+// interval, so tokens with made-up kids can't hammer the source.
+type KeySet struct {
+	source             KeySource
+	refreshInterval    time.Duration
+	minRefreshInterval time.Duration
+
+	mu          sync.RWMutex
+	keys        map[string]*jwk
+	loadedAt    time.Time
+	attemptedAt time.Time
+	refresh     singleflight.Group
+}
+
+// jwk is a parsed JSON web key
+type jwk struct {
+	kid string
+	alg string // Algorithm the key is restricted to, empty if unrestricted
+	key crypto.PublicKey
+}
+
+// NewKeySet creates a key set loading keys from source
+func NewKeySet(source KeySource, refreshInterval, minRefreshInterval time.Duration) *KeySet {
+	return &KeySet{
+		source:             source,
+		refreshInterval:    refreshInterval,
+		minRefreshInterval: minRefreshInterval,
+	}
+}
+
+// Key returns the verification key with the given kid
+// An empty kid selects the only key in a single-key set
+func (ks *KeySet) Key(ctx context.Context, kid string) (*jwk, error) {
+	key, found, fresh := ks.lookup(kid)
+	if found && fresh {
+		return key, nil
+	}
+
+	if ks.canRefresh() {
+		if err := ks.reload(ctx); err != nil {
+			// Keep verifying with the keys we have rather than failing every token
+			if found {
+				logger.WarnfWCtx(ctx, "Refreshing JWKS, using cached keys: %v", err)
+				return key, nil
+			}
+			return nil, keySetUnavailableError(err)
+		}
+		key, found, _ = ks.lookup(kid)
+	}
+
+	if !found {
+		if !ks.loaded() {
+			return nil, keySetUnavailableError(fmt.Errorf("JWKS not loaded"))
+		}
+		return nil, auth.NewAuthError(auth.ErrInvalidToken, "unknown signing key", http.StatusUnauthorized).
+			WithDetail("kid", kid)
+	}
+	return key, nil
+}
+
+// lookup finds a key in the loaded set and reports whether the set is within its refresh interval
+func (ks *KeySet) lookup(kid string) (key *jwk, found bool, fresh bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	fresh = time.Since(ks.loadedAt) < ks.refreshInterval
+	if kid == "" && len(ks.keys) == 1 {
+		for _, k := range ks.keys {
+			return k, true, fresh
+		}
+	}
+
+	key, found = ks.keys[kid]
+	return key, found, fresh
+}
+
+// canRefresh reports whether the set may be reloaded now, at most once per minimum refresh interval
+func (ks *KeySet) canRefresh() bool {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	return time.Since(ks.attemptedAt) >= ks.minRefreshInterval
+}
+
+// loaded reports whether the set has ever been loaded
+func (ks *KeySet) loaded() bool {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	return !ks.loadedAt.IsZero()
+}
+
+// keySetUnavailableError reports a key set that could not be loaded
+func keySetUnavailableError(err error) error {
+	return auth.NewAuthError(fmt.Errorf("%w: %w", auth.ErrServiceUnavailable, err), "JWKS unavailable", http.StatusServiceUnavailable)
+}
+
+// reload loads the key set from the source, coalescing concurrent reloads
+func (ks *KeySet) reload(ctx context.Context) error {
+	ch := ks.refresh.DoChan("jwks", func() (interface{}, error) {
+		ks.mu.Lock()
+		ks.attemptedAt = time.Now()
+		ks.mu.Unlock()
+
+		data, err := ks.source(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		keys, err := parseJWKS(data)
+		if err != nil {
+			return nil, err
+		}
+
+		ks.mu.Lock()
+		ks.keys = keys
+		ks.loadedAt = time.Now()
+		ks.mu.Unlock()
+
+		logger.InfofWCtx(ctx, "Loaded %d JWKS keys", len(keys))
+		return nil, nil
+	})
+
+	select {
+	case res := <-ch:
+		return res.Err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// jwkJSON is the JSON form of a JSON web key
+type jwkJSON struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS parses a JWKS document, skipping keys that aren't usable for signature verification
+func parseJWKS(data []byte) (map[string]*jwk, error) {
+	var doc struct {
+		Keys []jwkJSON `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing JWKS: %w", err)
+	}
+
+	keys := make(map[string]*jwk, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := parseJWK(k)
+		if err != nil {
+			logger.Warnf("Skipping JWKS key %q: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = &jwk{kid: k.Kid, alg: k.Alg, key: key}
+	}
+	return keys, nil
+}
+
+// parseJWK converts a JSON web key into a public key
+func parseJWK(k jwkJSON) (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid n: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, fmt.Errorf("invalid e")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y: %w", err)
+		}
+		curve := elliptic.P256()
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid x")
+		}
+		return ed25519.PublicKey(x), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// decodeBigInt decodes a base64url big-endian integer
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
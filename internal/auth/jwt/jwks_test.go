@@ -0,0 +1,115 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+)
+
+func TestKeySetRefreshesOnUnknownKid(t *testing.T) {
+	oldKey := newEdKey(t, "old")
+	newKey := newEdKey(t, "new")
+	src := &staticSource{data: jwks(t, oldKey)}
+	// A minimum refresh interval of zero lets every kid miss reload the set
+	s := NewService(NewKeySet(src.load, time.Hour, 0), DefaultConfig(), testPermissions)
+
+	ctx := context.Background()
+	if _, err := s.ValidateToken(ctx, oldKey.sign(t, validClaims())); err != nil {
+		t.Fatalf("ValidateToken with old key: %v", err)
+	}
+	if _, err := s.ValidateToken(ctx, oldKey.sign(t, validClaims())); err != nil {
+		t.Fatalf("ValidateToken with old key: %v", err)
+	}
+	if got := src.count(); got != 1 {
+		t.Fatalf("expected known kids to be served from the loaded set, got %d loads", got)
+	}
+
+	// Rotate the signing key within the refresh interval
+	src.set(jwks(t, oldKey, newKey))
+	if _, err := s.ValidateToken(ctx, newKey.sign(t, validClaims())); err != nil {
+		t.Fatalf("ValidateToken with rotated key: %v", err)
+	}
+	if got := src.count(); got != 2 {
+		t.Fatalf("expected a kid miss to reload the set, got %d loads", got)
+	}
+}
+
+func TestKeySetRateLimitsRefreshes(t *testing.T) {
+	key := newEdKey(t, "known")
+	unknown := newEdKey(t, "unknown")
+	src := &staticSource{data: jwks(t, key)}
+	s := NewService(NewKeySet(src.load, time.Hour, time.Hour), DefaultConfig(), testPermissions)
+
+	ctx := context.Background()
+	if _, err := s.ValidateToken(ctx, key.sign(t, validClaims())); err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+
+	// Made-up kids must not trigger a reload each time
+	for i := 0; i < 10; i++ {
+		_, err := s.ValidateToken(ctx, unknown.sign(t, validClaims()))
+		if !errors.Is(err, auth.ErrInvalidToken) {
+			t.Fatalf("expected unknown kid to be rejected, got %v", err)
+		}
+	}
+	if got := src.count(); got != 1 {
+		t.Fatalf("expected kid misses within the minimum refresh interval not to reload, got %d loads", got)
+	}
+}
+
+func TestKeySetKeepsCachedKeysWhenRefreshFails(t *testing.T) {
+	key := newEdKey(t, "known")
+	data := jwks(t, key)
+	fail := false
+	source := func(ctx context.Context) ([]byte, error) {
+		if fail {
+			return nil, errors.New("source down")
+		}
+		return data, nil
+	}
+	// A zero refresh interval makes every lookup stale
+	s := NewService(NewKeySet(source, 0, 0), DefaultConfig(), testPermissions)
+
+	ctx := context.Background()
+	if _, err := s.ValidateToken(ctx, key.sign(t, validClaims())); err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+
+	fail = true
+	if _, err := s.ValidateToken(ctx, key.sign(t, validClaims())); err != nil {
+		t.Fatalf("expected cached keys to be used while the source is down, got %v", err)
+	}
+}
+
+func TestKeySetUnavailable(t *testing.T) {
+	source := func(ctx context.Context) ([]byte, error) {
+		return nil, errors.New("source down")
+	}
+	s := NewService(NewKeySet(source, time.Hour, time.Minute), DefaultConfig(), testPermissions)
+
+	_, err := s.ValidateToken(context.Background(), newEdKey(t, "k").sign(t, validClaims()))
+	if !errors.Is(err, auth.ErrServiceUnavailable) {
+		t.Fatalf("expected service unavailable, got %v", err)
+	}
+}
+
+func TestParseJWKSSkipsUnusableKeys(t *testing.T) {
+	data := []byte(`{"keys": [
+		{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"},
+		{"kty": "EC", "kid": "p384", "crv": "P-384", "x": "AA", "y": "AA"},
+		{"kty": "EC", "kid": "offcurve", "crv": "P-256", "x": "AQ", "y": "AQ"},
+		{"kty": "OKP", "kid": "enc", "use": "enc", "crv": "Ed25519", "x": "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}
+	]}`)
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		t.Fatalf("parseJWKS: %v", err)
+	}
+	if len(keys) != 1 || keys["ed"] == nil {
+		t.Fatalf("expected only the Ed25519 signing key, got %v", keys)
+	}
+}
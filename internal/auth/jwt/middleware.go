@@ -0,0 +1,14 @@
+package jwt
+
+import (
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+)
+
+// NewMiddleware creates a provider authenticating requests by JWT
+// Tokens are read from the Authorization bearer token unless auth.WithTokenExtractors
+// is given. Opaque tokens in the same location are left to other providers in the chain.
+func NewMiddleware(service *Service, opts ...auth.TokenProviderOption) *auth.TokenProvider {
+	defaults := []auth.TokenExtractor{auth.FromBearer()}
+	opts = append([]auth.TokenProviderOption{auth.WithTokenFilter(looksLikeJWT)}, opts...)
+	return auth.NewTokenProvider("JWT", service.ValidateToken, service.enforcer, defaults, opts...)
+}
@@ -0,0 +1,195 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+	"github.com/jcsawyer123/simple-go-api/internal/auth/permission"
+)
+
+// AuthMethod is the auth method recorded on principals authenticated by a JWT
+const AuthMethod = "jwt"
+
+// Config controls how JWTs are validated and mapped onto principals
+type Config struct {
+	// Issuer the iss claim must equal, not checked if empty
+	Issuer string
+
+	// Audience the aud claim must contain, not checked if empty
+	Audience string
+
+	// Claim holding the caller's permissions, see permissionsFromClaim
+	PermissionsClaim string
+
+	// Claim holding the caller's account ID
+	AccountClaim string
+
+	// Clock skew tolerated when checking exp and nbf
+	Leeway time.Duration
+}
+
+// DefaultConfig returns the default JWT validation configuration
+func DefaultConfig() Config {
+	return Config{
+		PermissionsClaim: "permissions",
+		AccountClaim:     "account_id",
+		Leeway:           30 * time.Second,
+	}
+}
+
+// Service implements the auth.Service interface for JWTs verified locally against a JWKS
+type Service struct {
+	keys     *KeySet
+	cfg      Config
+	enforcer *permission.Enforcer
+}
+
+// Ensure Service implements the auth.Service interface
+var _ auth.Service = (*Service)(nil)
+
+// NewService creates a JWT service verifying tokens with keys from the key set
+// Required permissions are parsed through perms
+func NewService(keys *KeySet, cfg Config, perms *permission.Cache) *Service {
+	s := &Service{
+		keys: keys,
+		cfg:  cfg,
+	}
+	s.enforcer = permission.NewEnforcer(perms, auth.RequestPrincipal(AuthMethod, s.ValidateToken))
+	return s
+}
+
+// ValidateToken verifies a JWT's signature and claims and returns its principal
+func (s *Service) ValidateToken(ctx context.Context, raw string) (*auth.Principal, error) {
+	tok, err := parseToken(raw)
+	if err != nil {
+		return nil, invalidTokenError(err)
+	}
+
+	key, err := s.keys.Key(ctx, tok.header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := tok.verify(key); err != nil {
+		return nil, invalidTokenError(err)
+	}
+
+	claims, err := parseClaims(tok.payload)
+	if err != nil {
+		return nil, invalidTokenError(err)
+	}
+	if err := s.validateClaims(claims, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return s.principal(claims), nil
+}
+
+// validateClaims checks the registered claims against the configuration
+func (s *Service) validateClaims(claims *Claims, now time.Time) error {
+	if claims.ExpiresAt.IsZero() {
+		return invalidTokenError(fmt.Errorf("missing exp claim"))
+	}
+	if now.After(claims.ExpiresAt.Add(s.cfg.Leeway)) {
+		return auth.NewAuthError(auth.ErrExpiredToken, "JWT expired", http.StatusUnauthorized)
+	}
+	if !claims.NotBefore.IsZero() && now.Add(s.cfg.Leeway).Before(claims.NotBefore) {
+		return invalidTokenError(fmt.Errorf("token not valid yet"))
+	}
+	if s.cfg.Issuer != "" && claims.Issuer != s.cfg.Issuer {
+		return invalidTokenError(fmt.Errorf("unexpected issuer %q", claims.Issuer))
+	}
+	if s.cfg.Audience != "" && !claims.HasAudience(s.cfg.Audience) {
+		return invalidTokenError(fmt.Errorf("token not intended for this audience"))
+	}
+	return nil
+}
+
+// principal builds the principal identified by verified claims
+// The permissions claim forms a single role bound to the account claim
+func (s *Service) principal(claims *Claims) *auth.Principal {
+	permissions := permissionsFromClaim(claims.Raw[s.cfg.PermissionsClaim])
+	accountID := claims.String(s.cfg.AccountClaim)
+
+	name := claims.String("name")
+	if name == "" {
+		name = claims.Subject
+	}
+
+	return &auth.Principal{
+		ID:        claims.Subject,
+		Type:      auth.PrincipalUser,
+		Name:      name,
+		AccountID: accountID,
+		Roles: []auth.PrincipalRole{{
+			ID:          s.cfg.PermissionsClaim,
+			Name:        s.cfg.PermissionsClaim,
+			AccountID:   accountID,
+			Permissions: permissions,
+		}},
+		Permissions: permissions,
+		ExpiresAt:   claims.ExpiresAt,
+		AuthMethod:  AuthMethod,
+	}
+}
+
+// permissionsFromClaim maps a permissions claim onto a permission map
+// The claim may be a list of allowed permissions, a space separated string of allowed
+// permissions, or an object mapping permissions to "allowed" or "denied"
+func permissionsFromClaim(raw json.RawMessage) map[string]string {
+	permissions := make(map[string]string)
+	if len(raw) == 0 {
+		return permissions
+	}
+
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		for _, perm := range list {
+			permissions[perm] = "allowed"
+		}
+		return permissions
+	}
+
+	var str string
+	if err := json.Unmarshal(raw, &str); err == nil {
+		for _, perm := range strings.Fields(str) {
+			permissions[perm] = "allowed"
+		}
+		return permissions
+	}
+
+	var statuses map[string]string
+	if err := json.Unmarshal(raw, &statuses); err == nil {
+		for perm, status := range statuses {
+			if status == "allowed" || status == "denied" {
+				permissions[perm] = status
+			}
+		}
+	}
+	return permissions
+}
+
+// invalidTokenError reports a JWT that failed verification
+func invalidTokenError(err error) error {
+	return auth.NewAuthError(fmt.Errorf("%w: %w", auth.ErrInvalidToken, err), "invalid JWT", http.StatusUnauthorized)
+}
+
+// ValidatePermissions checks if the JWT has the required permission
+// requiredPerm may be a requirement expression, see permission.Requirement
+func (s *Service) ValidatePermissions(ctx context.Context, token, requiredPerm string) error {
+	return s.enforcer.ValidatePermissions(ctx, token, requiredPerm)
+}
+
+// ValidateAccountPermissions checks if the JWT has the required permission within an account
+func (s *Service) ValidateAccountPermissions(ctx context.Context, token, accountID, requiredPerm string) error {
+	return s.enforcer.ValidateAccountPermissions(ctx, token, accountID, requiredPerm)
+}
+
+// CreateMiddleware returns a middleware for this service
+func (s *Service) CreateMiddleware() auth.Middleware {
+	return NewMiddleware(s)
+}
@@ -0,0 +1,223 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Supported signing algorithms
+const (
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+var errSignature = errors.New("signature verification failed")
+
+// header is the JOSE header of a JWT
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+// token is a JWT split into its parts, not yet verified
+type token struct {
+	header       header
+	payload      []byte
+	signingInput string
+	signature    []byte
+}
+
+// looksLikeJWT reports whether a credential has the three-part JWT compact form
+// Other credentials are left to other providers in an auth chain
+func looksLikeJWT(raw string) bool {
+	return strings.Count(raw, ".") == 2 && strings.HasPrefix(raw, "eyJ")
+}
+
+// parseToken splits a compact JWT and decodes its header
+func parseToken(raw string) (*token, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed header: %w", err)
+	}
+	var h header
+	if err := json.Unmarshal(headerJSON, &h); err != nil {
+		return nil, fmt.Errorf("malformed header: %w", err)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed payload: %w", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature: %w", err)
+	}
+
+	return &token{
+		header:       h,
+		payload:      payload,
+		signingInput: parts[0] + "." + parts[1],
+		signature:    signature,
+	}, nil
+}
+
+// verify checks the token signature with a key from the key set
+// The algorithm must be one of the supported algorithms and match the key's type
+func (t *token) verify(key *jwk) error {
+	if key.alg != "" && key.alg != t.header.Alg {
+		return fmt.Errorf("algorithm %s not allowed for key %q", t.header.Alg, key.kid)
+	}
+
+	switch t.header.Alg {
+	case AlgRS256:
+		pub, ok := key.key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key %q is not an RSA key", key.kid)
+		}
+		digest := sha256.Sum256([]byte(t.signingInput))
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], t.signature); err != nil {
+			return errSignature
+		}
+
+	case AlgES256:
+		pub, ok := key.key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key %q is not an EC key", key.kid)
+		}
+		if len(t.signature) != 64 {
+			return errSignature
+		}
+		digest := sha256.Sum256([]byte(t.signingInput))
+		r := new(big.Int).SetBytes(t.signature[:32])
+		s := new(big.Int).SetBytes(t.signature[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return errSignature
+		}
+
+	case AlgEdDSA:
+		pub, ok := key.key.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("key %q is not an Ed25519 key", key.kid)
+		}
+		if !ed25519.Verify(pub, []byte(t.signingInput), t.signature) {
+			return errSignature
+		}
+
+	default:
+		return fmt.Errorf("unsupported algorithm %q", t.header.Alg)
+	}
+
+	return nil
+}
+
+// Claims are the verified claims of a JWT
+type Claims struct {
+	Issuer    string
+	Subject   string
+	Audience  []string
+	ExpiresAt time.Time
+	NotBefore time.Time
+	IssuedAt  time.Time
+
+	// Raw holds every claim, including the registered claims above
+	Raw map[string]json.RawMessage
+}
+
+// parseClaims decodes the token payload
+func parseClaims(payload []byte) (*Claims, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil, fmt.Errorf("malformed claims: %w", err)
+	}
+
+	var registered struct {
+		Iss string          `json:"iss"`
+		Sub string          `json:"sub"`
+		Aud json.RawMessage `json:"aud"`
+		Exp *json.Number    `json:"exp"`
+		Nbf *json.Number    `json:"nbf"`
+		Iat *json.Number    `json:"iat"`
+	}
+	if err := json.Unmarshal(payload, &registered); err != nil {
+		return nil, fmt.Errorf("malformed claims: %w", err)
+	}
+
+	c := &Claims{
+		Issuer:  registered.Iss,
+		Subject: registered.Sub,
+		Raw:     raw,
+	}
+
+	// aud may be a single string or an array of strings
+	if len(registered.Aud) > 0 {
+		var single string
+		if err := json.Unmarshal(registered.Aud, &single); err == nil {
+			c.Audience = []string{single}
+		} else if err := json.Unmarshal(registered.Aud, &c.Audience); err != nil {
+			return nil, fmt.Errorf("malformed aud claim")
+		}
+	}
+
+	var err error
+	if c.ExpiresAt, err = numericDate(registered.Exp); err != nil {
+		return nil, fmt.Errorf("malformed exp claim: %w", err)
+	}
+	if c.NotBefore, err = numericDate(registered.Nbf); err != nil {
+		return nil, fmt.Errorf("malformed nbf claim: %w", err)
+	}
+	if c.IssuedAt, err = numericDate(registered.Iat); err != nil {
+		return nil, fmt.Errorf("malformed iat claim: %w", err)
+	}
+
+	return c, nil
+}
+
+// numericDate converts a JWT NumericDate, seconds since the epoch, into a time
+func numericDate(n *json.Number) (time.Time, error) {
+	if n == nil {
+		return time.Time{}, nil
+	}
+
+	secs, err := n.Float64()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, int64(secs*float64(time.Second))), nil
+}
+
+// String returns a string claim, empty if missing or not a string
+func (c *Claims) String(name string) string {
+	var s string
+	if raw, ok := c.Raw[name]; ok {
+		_ = json.Unmarshal(raw, &s)
+	}
+	return s
+}
+
+// HasAudience reports whether the token is intended for the audience
+func (c *Claims) HasAudience(audience string) bool {
+	for _, aud := range c.Audience {
+		if aud == audience {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,390 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+	"github.com/jcsawyer123/simple-go-api/internal/auth/permission"
+)
+
+// testPermissions is the parsed permission cache shared by the services under test
+var testPermissions = permission.NewCache(permission.Parser{}, 0)
+
+// testKey is a signing key and its public JWK
+type testKey struct {
+	kid     string
+	alg     string
+	private crypto.Signer
+	jwk     map[string]string
+}
+
+var (
+	rsaKeyOnce sync.Once
+	rsaKey     *rsa.PrivateKey
+)
+
+func newRSAKey(t *testing.T, kid string) *testKey {
+	t.Helper()
+	// RSA key generation is slow, share one key across tests
+	rsaKeyOnce.Do(func() {
+		var err error
+		if rsaKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+			panic(err)
+		}
+	})
+	return &testKey{
+		kid:     kid,
+		alg:     AlgRS256,
+		private: rsaKey,
+		jwk: map[string]string{
+			"kty": "RSA",
+			"kid": kid,
+			"n":   b64(rsaKey.N.Bytes()),
+			"e":   b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+		},
+	}
+}
+
+func newECKey(t *testing.T, kid string) *testKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testKey{
+		kid:     kid,
+		alg:     AlgES256,
+		private: key,
+		jwk: map[string]string{
+			"kty": "EC",
+			"kid": kid,
+			"crv": "P-256",
+			"x":   b64(key.X.FillBytes(make([]byte, 32))),
+			"y":   b64(key.Y.FillBytes(make([]byte, 32))),
+		},
+	}
+}
+
+func newEdKey(t *testing.T, kid string) *testKey {
+	t.Helper()
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testKey{
+		kid:     kid,
+		alg:     AlgEdDSA,
+		private: key,
+		jwk: map[string]string{
+			"kty": "OKP",
+			"kid": kid,
+			"crv": "Ed25519",
+			"x":   b64(pub),
+		},
+	}
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// jwks builds a JWKS document holding the keys
+func jwks(t *testing.T, keys ...*testKey) []byte {
+	t.Helper()
+	doc := struct {
+		Keys []map[string]string `json:"keys"`
+	}{}
+	for _, k := range keys {
+		doc.Keys = append(doc.Keys, k.jwk)
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// signingInput encodes a header and claims into the first two parts of a JWT
+func signingInput(t *testing.T, hdr map[string]string, claims map[string]interface{}) string {
+	t.Helper()
+	h, err := json.Marshal(hdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b64(h) + "." + b64(c)
+}
+
+// sign creates a JWT signed with the key using the key's algorithm
+func (k *testKey) sign(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+	input := signingInput(t, map[string]string{"alg": k.alg, "kid": k.kid, "typ": "JWT"}, claims)
+	digest := sha256.Sum256([]byte(input))
+
+	var sig []byte
+	var err error
+	switch key := k.private.(type) {
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, key, digest[:])
+		if err == nil {
+			sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(key, []byte(input))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + b64(sig)
+}
+
+// signature returns the encoded signature part of a JWT
+func signature(token string) string {
+	return token[strings.LastIndex(token, ".")+1:]
+}
+
+// staticSource serves a fixed JWKS document and counts loads
+type staticSource struct {
+	mu    sync.Mutex
+	data  []byte
+	loads int
+}
+
+func (s *staticSource) load(ctx context.Context) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loads++
+	return s.data, nil
+}
+
+func (s *staticSource) set(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data = data
+}
+
+func (s *staticSource) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loads
+}
+
+func newTestService(t *testing.T, cfg Config, keys ...*testKey) *Service {
+	t.Helper()
+	src := &staticSource{data: jwks(t, keys...)}
+	return NewService(NewKeySet(src.load, time.Hour, time.Minute), cfg, testPermissions)
+}
+
+func validClaims() map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"sub":         "user-1",
+		"iss":         "https://issuer.example",
+		"aud":         "api",
+		"exp":         now.Add(time.Hour).Unix(),
+		"nbf":         now.Add(-time.Minute).Unix(),
+		"iat":         now.Unix(),
+		"account_id":  "2",
+		"permissions": []string{"iam:read:users"},
+	}
+}
+
+func TestValidateTokenAlgorithms(t *testing.T) {
+	keys := []*testKey{newRSAKey(t, "rsa"), newECKey(t, "ec"), newEdKey(t, "ed")}
+	s := newTestService(t, DefaultConfig(), keys...)
+
+	for _, key := range keys {
+		t.Run(key.alg, func(t *testing.T) {
+			principal, err := s.ValidateToken(context.Background(), key.sign(t, validClaims()))
+			if err != nil {
+				t.Fatalf("ValidateToken: %v", err)
+			}
+			if principal.ID != "user-1" || principal.AccountID != "2" || principal.AuthMethod != AuthMethod {
+				t.Errorf("unexpected principal %+v", principal)
+			}
+			if principal.Permissions["iam:read:users"] != "allowed" {
+				t.Errorf("permissions claim not mapped: %v", principal.Permissions)
+			}
+		})
+	}
+}
+
+func TestValidateTokenRejectsAlgorithmAttacks(t *testing.T) {
+	rsaKey := newRSAKey(t, "rsa")
+	ecKey := newECKey(t, "ec")
+	s := newTestService(t, DefaultConfig(), rsaKey, ecKey)
+
+	pubDER, err := x509.MarshalPKIXPublicKey(rsaKey.private.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token func(t *testing.T) string
+	}{
+		{
+			name: "alg none",
+			token: func(t *testing.T) string {
+				return signingInput(t, map[string]string{"alg": "none", "kid": "rsa"}, validClaims()) + "."
+			},
+		},
+		{
+			name: "alg none without kid",
+			token: func(t *testing.T) string {
+				return signingInput(t, map[string]string{"alg": "none"}, validClaims()) + "."
+			},
+		},
+		{
+			// An RS256 signature presented as ES256 against the RSA key
+			name: "alg key type mismatch",
+			token: func(t *testing.T) string {
+				sig := signature(rsaKey.sign(t, validClaims()))
+				return signingInput(t, map[string]string{"alg": AlgES256, "kid": "rsa"}, validClaims()) + "." + sig
+			},
+		},
+		{
+			name: "EdDSA against EC key",
+			token: func(t *testing.T) string {
+				return signingInput(t, map[string]string{"alg": AlgEdDSA, "kid": "ec"}, validClaims()) + "." + b64(make([]byte, 64))
+			},
+		},
+		{
+			// HMAC keyed with the RSA public key, the classic algorithm confusion attack
+			name: "HS256 with RSA public key",
+			token: func(t *testing.T) string {
+				input := signingInput(t, map[string]string{"alg": "HS256", "kid": "rsa"}, validClaims())
+				mac := hmac.New(sha256.New, pubDER)
+				mac.Write([]byte(input))
+				return input + "." + b64(mac.Sum(nil))
+			},
+		},
+		{
+			name: "tampered payload",
+			token: func(t *testing.T) string {
+				sig := signature(ecKey.sign(t, validClaims()))
+				claims := validClaims()
+				claims["permissions"] = []string{"*"}
+				return signingInput(t, map[string]string{"alg": AlgES256, "kid": "ec", "typ": "JWT"}, claims) + "." + sig
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.ValidateToken(context.Background(), tt.token(t))
+			if !errors.Is(err, auth.ErrInvalidToken) {
+				t.Fatalf("expected invalid token, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateTokenRestrictedKeyAlgorithm(t *testing.T) {
+	key := newRSAKey(t, "rsa")
+	key.jwk["alg"] = AlgES256
+	s := newTestService(t, DefaultConfig(), key)
+
+	_, err := s.ValidateToken(context.Background(), key.sign(t, validClaims()))
+	if !errors.Is(err, auth.ErrInvalidToken) {
+		t.Fatalf("expected key restricted to ES256 to reject RS256, got %v", err)
+	}
+}
+
+func TestValidateClaims(t *testing.T) {
+	key := newEdKey(t, "ed")
+	cfg := DefaultConfig()
+	cfg.Issuer = "https://issuer.example"
+	cfg.Audience = "api"
+	cfg.Leeway = 30 * time.Second
+	s := newTestService(t, cfg, key)
+
+	now := time.Now()
+	tests := []struct {
+		name    string
+		mutate  func(claims map[string]interface{})
+		wantErr error
+	}{
+		{name: "valid", mutate: func(c map[string]interface{}) {}},
+		{name: "expired", mutate: func(c map[string]interface{}) { c["exp"] = now.Add(-time.Minute).Unix() }, wantErr: auth.ErrExpiredToken},
+		{name: "expired within leeway", mutate: func(c map[string]interface{}) { c["exp"] = now.Add(-10 * time.Second).Unix() }},
+		{name: "missing exp", mutate: func(c map[string]interface{}) { delete(c, "exp") }, wantErr: auth.ErrInvalidToken},
+		{name: "not yet valid", mutate: func(c map[string]interface{}) { c["nbf"] = now.Add(time.Minute).Unix() }, wantErr: auth.ErrInvalidToken},
+		{name: "not yet valid within leeway", mutate: func(c map[string]interface{}) { c["nbf"] = now.Add(10 * time.Second).Unix() }},
+		{name: "wrong issuer", mutate: func(c map[string]interface{}) { c["iss"] = "https://evil.example" }, wantErr: auth.ErrInvalidToken},
+		{name: "missing issuer", mutate: func(c map[string]interface{}) { delete(c, "iss") }, wantErr: auth.ErrInvalidToken},
+		{name: "wrong audience", mutate: func(c map[string]interface{}) { c["aud"] = "other" }, wantErr: auth.ErrInvalidToken},
+		{name: "audience list", mutate: func(c map[string]interface{}) { c["aud"] = []string{"other", "api"} }},
+		{name: "audience list without api", mutate: func(c map[string]interface{}) { c["aud"] = []string{"other"} }, wantErr: auth.ErrInvalidToken},
+		{name: "malformed exp", mutate: func(c map[string]interface{}) { c["exp"] = "tomorrow" }, wantErr: auth.ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			tt.mutate(claims)
+
+			_, err := s.ValidateToken(context.Background(), key.sign(t, claims))
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("ValidateToken: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPermissionsFromClaim(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want map[string]string
+	}{
+		{name: "list", raw: `["a:b", "c:d"]`, want: map[string]string{"a:b": "allowed", "c:d": "allowed"}},
+		{name: "space separated", raw: `"a:b c:d"`, want: map[string]string{"a:b": "allowed", "c:d": "allowed"}},
+		{name: "statuses", raw: `{"a:b": "allowed", "c:d": "denied", "e:f": "maybe"}`, want: map[string]string{"a:b": "allowed", "c:d": "denied"}},
+		{name: "missing", raw: ``, want: map[string]string{}},
+		{name: "number", raw: `42`, want: map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := permissionsFromClaim(json.RawMessage(tt.raw))
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for perm, status := range tt.want {
+				if got[perm] != status {
+					t.Errorf("%s: got %q, want %q", perm, got[perm], status)
+				}
+			}
+		})
+	}
+}
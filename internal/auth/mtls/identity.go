@@ -0,0 +1,163 @@
+package mtls
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+	"github.com/jcsawyer123/simple-go-api/internal/auth/permission"
+)
+
+// IdentityFile is the on-disk format of the certificate identity mapping
+//
+//	{
+//	  "identities": [
+//	    {
+//	      "id": "billing",
+//	      "uri": "spiffe://example.org/ns/prod/sa/billing",
+//	      "account_id": "*",
+//	      "permissions": ["myservice:*:read:*"]
+//	    },
+//	    {
+//	      "id": "reporting",
+//	      "common_name": "reporting.internal",
+//	      "account_id": "12345",
+//	      "permissions": ["myservice:*:export:*"]
+//	    }
+//	  ]
+//	}
+//
+// Each identity sets exactly one of uri (a URI SAN such as a SPIFFE ID), dns (a DNS SAN)
+// or common_name (the subject CN)
+type IdentityFile struct {
+	Identities []Identity `json:"identities"`
+}
+
+// Identity maps a certificate identity onto a principal
+type Identity struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	URI         string   `json:"uri"`
+	DNS         string   `json:"dns"`
+	CommonName  string   `json:"common_name"`
+	AccountID   string   `json:"account_id"`
+	Permissions []string `json:"permissions"`
+}
+
+// Identity matcher kinds, in the order certificates are matched
+const (
+	matchURI        = "uri"
+	matchDNS        = "dns"
+	matchCommonName = "common_name"
+)
+
+// IdentityMap resolves verified client certificates to principals
+type IdentityMap struct {
+	principals map[string]map[string]*auth.Principal // matcher kind -> value -> principal
+}
+
+// LoadIdentityMap reads and validates an identity mapping file, validating permissions with parser
+func LoadIdentityMap(path string, parser permission.Parser) (*IdentityMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading mTLS identity file: %w", err)
+	}
+
+	var file IdentityFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing mTLS identity file %s: %w", path, err)
+	}
+
+	m := &IdentityMap{
+		principals: map[string]map[string]*auth.Principal{
+			matchURI:        {},
+			matchDNS:        {},
+			matchCommonName: {},
+		},
+	}
+	for i := range file.Identities {
+		if err := m.add(&file.Identities[i], parser); err != nil {
+			return nil, fmt.Errorf("parsing mTLS identity file %s: %w", path, err)
+		}
+	}
+	return m, nil
+}
+
+// add validates an identity and indexes its principal
+func (m *IdentityMap) add(identity *Identity, parser permission.Parser) error {
+	if identity.ID == "" {
+		return fmt.Errorf("identity missing id")
+	}
+
+	var kind, value string
+	for k, v := range map[string]string{matchURI: identity.URI, matchDNS: identity.DNS, matchCommonName: identity.CommonName} {
+		if v == "" {
+			continue
+		}
+		if kind != "" {
+			return fmt.Errorf("identity %s: set only one of uri, dns or common_name", identity.ID)
+		}
+		kind, value = k, v
+	}
+	if kind == "" {
+		return fmt.Errorf("identity %s: one of uri, dns or common_name is required", identity.ID)
+	}
+	if _, exists := m.principals[kind][value]; exists {
+		return fmt.Errorf("identity %s: duplicate %s %q", identity.ID, kind, value)
+	}
+
+	permissions := make(map[string]string, len(identity.Permissions))
+	for _, permStr := range identity.Permissions {
+		if _, err := parser.Parse(permStr); err != nil {
+			return fmt.Errorf("identity %s: %w", identity.ID, err)
+		}
+		permissions[permStr] = "allowed"
+	}
+
+	name := identity.Name
+	if name == "" {
+		name = value
+	}
+
+	m.principals[kind][value] = &auth.Principal{
+		ID:        identity.ID,
+		Type:      auth.PrincipalService,
+		Name:      name,
+		AccountID: identity.AccountID,
+		Roles: []auth.PrincipalRole{{
+			ID:          identity.ID,
+			Name:        name,
+			AccountID:   identity.AccountID,
+			Permissions: permissions,
+		}},
+		Permissions: permissions,
+		AuthMethod:  AuthMethod,
+	}
+	return nil
+}
+
+// Lookup returns the principal mapped to a certificate and the identity it matched on
+// URI SANs are matched first, then DNS SANs, then the subject common name
+func (m *IdentityMap) Lookup(cert *x509.Certificate) (*auth.Principal, string, bool) {
+	for _, uri := range cert.URIs {
+		if principal, ok := m.principals[matchURI][uri.String()]; ok {
+			return principal, uri.String(), true
+		}
+	}
+
+	for _, dns := range cert.DNSNames {
+		if principal, ok := m.principals[matchDNS][dns]; ok {
+			return principal, dns, true
+		}
+	}
+
+	if cn := cert.Subject.CommonName; cn != "" {
+		if principal, ok := m.principals[matchCommonName][cn]; ok {
+			return principal, cn, true
+		}
+	}
+
+	return nil, "", false
+}
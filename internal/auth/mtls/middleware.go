@@ -0,0 +1,58 @@
+package mtls
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+)
+
+// Middleware implements the auth.Middleware interface for TLS client certificates
+type Middleware struct {
+	service *Service
+}
+
+// Ensure Middleware implements the auth.Provider interface
+var _ auth.Provider = (*Middleware)(nil)
+
+// NewMiddleware creates a new client certificate middleware
+func NewMiddleware(service *Service) *Middleware {
+	return &Middleware{service: service}
+}
+
+// Authenticate implements the auth.Middleware interface
+func (m *Middleware) Authenticate(next http.Handler) http.Handler {
+	return m.AuthenticateWith()(next)
+}
+
+// AuthenticateWith implements the auth.Middleware interface
+// Client certificates are always read from the TLS connection, so extractors are ignored
+func (m *Middleware) AuthenticateWith(extractors ...auth.TokenExtractor) func(http.Handler) http.Handler {
+	return auth.AuthenticateWith(m, extractors)
+}
+
+// AuthenticateRequest implements the auth.Authenticator interface
+// Only certificates verified against the client CA bundle are accepted
+func (m *Middleware) AuthenticateRequest(r *http.Request, _ []auth.TokenExtractor) (context.Context, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, auth.NewAuthError(auth.ErrMissingToken, "no verified client certificate", http.StatusUnauthorized)
+	}
+
+	principal, identity, err := m.service.ValidateCertificate(r.TLS.VerifiedChains[0][0])
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := auth.WithToken(r.Context(), identity)
+	return auth.WithPrincipal(ctx, principal), nil
+}
+
+// RequirePermissions implements the auth.Middleware interface
+func (m *Middleware) RequirePermissions(requiredPerm string) func(http.Handler) http.Handler {
+	return m.service.enforcer.RequirePermissions(requiredPerm)
+}
+
+// RequireAccountPermissions implements the auth.Middleware interface
+func (m *Middleware) RequireAccountPermissions(requiredPerm string, resolve auth.AccountResolver) func(http.Handler) http.Handler {
+	return m.service.enforcer.RequireAccountPermissions(requiredPerm, resolve)
+}
@@ -0,0 +1,74 @@
+package mtls
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"time"
+
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+	"github.com/jcsawyer123/simple-go-api/internal/auth/permission"
+)
+
+// AuthMethod is the auth method recorded on principals authenticated by a client certificate
+const AuthMethod = "mtls"
+
+// Service implements the auth.Service interface for verified TLS client certificates
+//
+// Certificates are verified against the client CA bundle during the TLS handshake, so
+// the service only maps them onto principals. The certificate identity that matched is
+// stored in the context as the request's token.
+type Service struct {
+	identities *IdentityMap
+	enforcer   *permission.Enforcer
+}
+
+// Ensure Service implements the auth.Service interface
+var _ auth.Service = (*Service)(nil)
+
+// NewService creates a client certificate service mapping certificates through an identity map
+// Required permissions are parsed through perms
+func NewService(identities *IdentityMap, perms *permission.Cache) *Service {
+	s := &Service{identities: identities}
+	s.enforcer = permission.NewEnforcer(perms, auth.RequestPrincipal(AuthMethod, s.ValidateToken))
+	return s
+}
+
+// ValidateCertificate returns the principal for a client certificate verified by the TLS handshake
+// It also reports the certificate identity the principal was matched on
+func (s *Service) ValidateCertificate(cert *x509.Certificate) (*auth.Principal, string, error) {
+	if time.Now().After(cert.NotAfter) {
+		return nil, "", auth.NewAuthError(auth.ErrExpiredToken, "client certificate expired", http.StatusUnauthorized)
+	}
+
+	principal, identity, ok := s.identities.Lookup(cert)
+	if !ok {
+		return nil, "", auth.NewAuthError(auth.ErrInvalidToken, "client certificate identity not recognised", http.StatusUnauthorized).
+			WithDetail("subject", cert.Subject.String())
+	}
+	return principal, identity, nil
+}
+
+// ValidateToken implements the auth.Service interface
+// A certificate identity on its own proves nothing, so tokens are always rejected; callers
+// authenticate through the middleware, which reads the verified certificate
+func (s *Service) ValidateToken(ctx context.Context, token string) (*auth.Principal, error) {
+	return nil, auth.NewAuthError(auth.ErrInvalidToken, "client certificate identities can only be validated from a TLS connection", http.StatusUnauthorized)
+}
+
+// ValidatePermissions checks if the certificate's identity has the required permission
+// Only requests authenticated by the middleware can be checked
+func (s *Service) ValidatePermissions(ctx context.Context, token, requiredPerm string) error {
+	return s.enforcer.ValidatePermissions(ctx, token, requiredPerm)
+}
+
+// ValidateAccountPermissions checks if the certificate's identity has the required permission within an account
+// Only requests authenticated by the middleware can be checked
+func (s *Service) ValidateAccountPermissions(ctx context.Context, token, accountID, requiredPerm string) error {
+	return s.enforcer.ValidateAccountPermissions(ctx, token, accountID, requiredPerm)
+}
+
+// CreateMiddleware returns a middleware for this service
+func (s *Service) CreateMiddleware() auth.Middleware {
+	return NewMiddleware(s)
+}
@@ -0,0 +1,81 @@
+package permission
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/jcsawyer123/simple-go-api/internal/audit"
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+	"github.com/jcsawyer123/simple-go-api/internal/logger"
+)
+
+// recordDecision records the outcome of a permission check in the audit log
+// accountID is empty for checks that aren't scoped to an account; parser compiles the
+// principal's permissions to report the grant that matched
+func recordDecision(r *http.Request, parser Parser, accountID, requiredPerm string, required Requirement, err error) {
+	if !audit.Enabled() {
+		return
+	}
+
+	ctx := r.Context()
+	e := audit.Event{
+		Time:               time.Now().UTC(),
+		RequestID:          middleware.GetReqID(ctx),
+		AccountID:          accountID,
+		Method:             r.Method,
+		Path:               r.URL.Path,
+		RequiredPermission: requiredPerm,
+		Outcome:            decisionOutcome(err),
+		Cached:             auth.AuthCachedFromContext(ctx),
+	}
+	if required != nil {
+		e.RequiredPermission = required.String()
+	}
+	if rctx := chi.RouteContext(ctx); rctx != nil {
+		e.Route = rctx.RoutePattern()
+	}
+	e.Provider, _ = auth.AuthProviderFromContext(ctx)
+
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if ok {
+		e.PrincipalID = principal.ID
+		e.PrincipalType = string(principal.Type)
+		e.AuthMethod = principal.AuthMethod
+	}
+
+	switch {
+	case err == nil && ok && required != nil:
+		e.MatchedPermission = strings.Join(Matched(required, parser.PrincipalPermissions(principal, accountID)), ", ")
+	case err != nil:
+		e.Error = err.Error()
+
+		var authErr *auth.AuthError
+		if errors.As(err, &authErr) {
+			if denied, ok := authErr.Details["denied_permission"].(string); ok {
+				e.MatchedPermission = denied
+			}
+		}
+	}
+
+	if err := audit.Record(e); err != nil {
+		logger.ErrorfWCtx(ctx, "Recording audit event: %v", err)
+	}
+}
+
+// decisionOutcome classifies the result of a permission check
+func decisionOutcome(err error) string {
+	switch {
+	case err == nil:
+		return audit.OutcomeAllowed
+	case errors.Is(err, auth.ErrPermissionDenied):
+		return audit.OutcomeDenied
+	case errors.Is(err, auth.ErrInsufficientPermissions):
+		return audit.OutcomeInsufficient
+	default:
+		return audit.OutcomeError
+	}
+}
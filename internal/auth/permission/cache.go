@@ -0,0 +1,98 @@
+package permission
+
+import (
+	"github.com/jcsawyer123/simple-go-api/internal/auth/cache"
+	"github.com/jcsawyer123/simple-go-api/internal/metrics"
+)
+
+// DefaultCacheSize is the number of parsed permissions, and of parsed requirement
+// expressions, a Cache keeps unless NewCache is given another bound
+const DefaultCacheSize = 10000
+
+// Cache is a bounded cache of parsed permissions and requirement expressions
+//
+// Parsing is pure, so one Cache can be shared by every auth provider. Its metrics are
+// registered when it is created, so a process should create one and pass it around.
+type Cache struct {
+	parser       Parser
+	permissions  *parsedCache
+	requirements *parsedCache
+}
+
+// NewCache creates a cache of values parsed by parser, holding at most maxEntries parsed
+// permissions and as many requirement expressions, or DefaultCacheSize of each if maxEntries
+// is not positive. It should be called once the global metrics reporter is set up.
+func NewCache(parser Parser, maxEntries int) *Cache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultCacheSize
+	}
+
+	return &Cache{
+		parser:       parser,
+		permissions:  newParsedCache("parsed_permission", maxEntries),
+		requirements: newParsedCache("parsed_requirement", maxEntries),
+	}
+}
+
+// Parser returns the parser the cache parses values with
+func (c *Cache) Parser() Parser {
+	return c.parser
+}
+
+// parsedCache is a bounded cache of parsed values with hit, miss and eviction metrics
+type parsedCache struct {
+	cache  *cache.LRUCache
+	hits   metrics.Counter
+	misses metrics.Counter
+}
+
+// newParsedCache creates a parsed value cache reporting metrics under the aims_<name>_cache_ prefix
+func newParsedCache(name string, maxEntries int) *parsedCache {
+	evictions := metrics.CounterMetric("aims_"+name+"_cache_evictions_total", nil)
+	return &parsedCache{
+		cache:  cache.NewLRUCache(maxEntries, func(string) { evictions.Inc() }),
+		hits:   metrics.CounterMetric("aims_"+name+"_cache_hits_total", nil),
+		misses: metrics.CounterMetric("aims_"+name+"_cache_misses_total", nil),
+	}
+}
+
+// get retrieves a parsed value, counting the hit or miss
+func (c *parsedCache) get(key string) (interface{}, bool) {
+	if val, ok := c.cache.Get(key); ok {
+		c.hits.Inc()
+		return val, true
+	}
+	c.misses.Inc()
+	return nil, false
+}
+
+// Permission gets a permission from cache or parses it
+func (c *Cache) Permission(perm string) (*Permission, error) {
+	if val, ok := c.permissions.get(perm); ok {
+		return val.(*Permission), nil
+	}
+
+	p, err := c.parser.Parse(perm)
+	if err != nil {
+		return nil, err
+	}
+
+	c.permissions.cache.Set(perm, p)
+	return p, nil
+}
+
+// Requirement gets a requirement expression from cache or parses it
+// Permissions within the expression are parsed through Permission, so they share its bound
+func (c *Cache) Requirement(expr string) (Requirement, error) {
+	if val, ok := c.requirements.get(expr); ok {
+		return val.(Requirement), nil
+	}
+
+	req, err := parseRequirement(expr, c.Permission)
+	if err != nil {
+		return nil, err
+	}
+
+	c.requirements.cache.Set(expr, req)
+	return req, nil
+}
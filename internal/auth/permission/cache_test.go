@@ -0,0 +1,49 @@
+package permission
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCacheRequirementIsBounded(t *testing.T) {
+	const maxEntries = 100
+
+	c := NewCache(Parser{}, maxEntries)
+	for i := 0; i < maxEntries+10; i++ {
+		if _, err := c.Requirement(fmt.Sprintf("any(svc:read:res%d, svc:list:*)", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := c.requirements.cache.Len(); n > maxEntries {
+		t.Fatalf("requirement cache grew to %d entries", n)
+	}
+	if n := c.permissions.cache.Len(); n > maxEntries {
+		t.Fatalf("permission cache grew to %d entries", n)
+	}
+
+	first, err := c.Requirement("svc:read:users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.Requirement("svc:read:users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Fatal("expected a cached requirement to be reused")
+	}
+}
+
+func TestNewCacheDefaultsSize(t *testing.T) {
+	c := NewCache(Parser{}, 0)
+	for i := 0; i < DefaultCacheSize+10; i++ {
+		if _, err := c.Permission(fmt.Sprintf("svc:read:res%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := c.permissions.cache.Len(); n != DefaultCacheSize {
+		t.Fatalf("expected the default bound of %d entries, got %d", DefaultCacheSize, n)
+	}
+}
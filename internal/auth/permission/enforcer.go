@@ -0,0 +1,167 @@
+package permission
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+)
+
+// PrincipalSource returns the principal a token authenticates as
+type PrincipalSource = auth.TokenValidator
+
+// Enforcer checks requirement expressions against the permissions of authenticated principals
+//
+// It backs the permission checks of every auth provider, so requirements are written in the
+// same permission grammar and behave the same however the caller authenticated
+type Enforcer struct {
+	cache     *Cache
+	principal PrincipalSource
+}
+
+// NewEnforcer creates an enforcer for principals resolved by source, parsing requirements through cache
+func NewEnforcer(cache *Cache, source PrincipalSource) *Enforcer {
+	return &Enforcer{
+		cache:     cache,
+		principal: source,
+	}
+}
+
+// ValidatePermissions checks if the token's principal satisfies the required permission expression
+func (e *Enforcer) ValidatePermissions(ctx context.Context, token, requiredPerm string) error {
+	req, err := e.cache.Requirement(requiredPerm)
+	if err != nil {
+		return invalidRequirementError(requiredPerm, err)
+	}
+
+	return e.validateRequirement(ctx, token, req)
+}
+
+// ValidateAccountPermissions checks if the token's principal satisfies the required permission
+// expression within an account
+func (e *Enforcer) ValidateAccountPermissions(ctx context.Context, token, accountID, requiredPerm string) error {
+	req, err := e.cache.Requirement(requiredPerm)
+	if err != nil {
+		return invalidRequirementError(requiredPerm, err)
+	}
+
+	return e.validateAccountRequirement(ctx, token, accountID, req)
+}
+
+// validateRequirement checks a parsed requirement against the principal's combined permissions
+func (e *Enforcer) validateRequirement(ctx context.Context, token string, req Requirement) error {
+	principal, err := e.principal(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	return req.Check(e.cache.parser.PrincipalPermissions(principal, ""))
+}
+
+// validateAccountRequirement checks a parsed requirement against the principal's permissions within an account
+func (e *Enforcer) validateAccountRequirement(ctx context.Context, token, accountID string, req Requirement) error {
+	principal, err := e.principal(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	return req.Check(e.cache.parser.PrincipalPermissions(principal, accountID))
+}
+
+// RequirePermissions implements the auth.Middleware method
+// requiredPerm may be a requirement expression over permission templates, see Requirement
+// and Permission.IsTemplate. Every decision is recorded in the audit log.
+func (e *Enforcer) RequirePermissions(requiredPerm string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := auth.TokenFromContext(r.Context())
+			if !ok {
+				recordDecision(r, e.cache.parser, "", requiredPerm, nil, errNoTokenInContext)
+				writeError(w, r, errNoTokenInContext, requiredPerm)
+				return
+			}
+
+			required, err := e.requiredPermission(r, requiredPerm)
+			if err != nil {
+				recordDecision(r, e.cache.parser, "", requiredPerm, nil, err)
+				writeError(w, r, err, requiredPerm)
+				return
+			}
+
+			err = e.validateRequirement(r.Context(), token, required)
+			recordDecision(r, e.cache.parser, "", requiredPerm, required, err)
+			if err != nil {
+				writeError(w, r, err, required.String())
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireAccountPermissions implements the auth.Middleware method
+// requiredPerm may be a requirement expression over permission templates, see Requirement
+// and Permission.IsTemplate
+func (e *Enforcer) RequireAccountPermissions(requiredPerm string, resolve auth.AccountResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := auth.TokenFromContext(r.Context())
+			if !ok {
+				recordDecision(r, e.cache.parser, "", requiredPerm, nil, errNoTokenInContext)
+				writeError(w, r, errNoTokenInContext, requiredPerm)
+				return
+			}
+
+			accountID := resolve(r)
+			if accountID == "" {
+				recordDecision(r, e.cache.parser, "", requiredPerm, nil, errNoAccount)
+				writeError(w, r, errNoAccount, requiredPerm)
+				return
+			}
+
+			required, err := e.requiredPermission(r, requiredPerm)
+			if err != nil {
+				recordDecision(r, e.cache.parser, accountID, requiredPerm, nil, err)
+				writeError(w, r, err, requiredPerm)
+				return
+			}
+
+			err = e.validateAccountRequirement(r.Context(), token, accountID, required)
+			recordDecision(r, e.cache.parser, accountID, requiredPerm, required, err)
+			if err != nil {
+				writeError(w, r, err, required.String())
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requiredPermission parses the required permission expression, using the cached skeleton
+// for templates, and fills any placeholders from the request
+func (e *Enforcer) requiredPermission(r *http.Request, requiredPerm string) (Requirement, error) {
+	req, err := e.cache.Requirement(requiredPerm)
+	if err != nil {
+		return nil, invalidRequirementError(requiredPerm, err)
+	}
+
+	resolved, err := req.Resolve(r)
+	if err != nil {
+		return nil, unresolvedRequirementError(requiredPerm, err)
+	}
+	return resolved, nil
+}
+
+var (
+	errNoTokenInContext = auth.NewAuthError(auth.ErrMissingToken, "no token in context", http.StatusUnauthorized)
+	errNoAccount        = auth.NewAuthError(auth.ErrInvalidRequest, "no account specified", http.StatusBadRequest)
+)
+
+// writeError renders an auth failure as problem details naming the required permission
+func writeError(w http.ResponseWriter, r *http.Request, err error, requiredPerm string) {
+	p := auth.NewProblem(r, err)
+	p.Extensions["required_permission"] = requiredPerm
+	auth.WriteProblem(w, p)
+}
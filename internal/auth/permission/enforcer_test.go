@@ -0,0 +1,185 @@
+package permission
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+)
+
+// testPermissions is the parsed permission cache shared by the enforcers under test
+var testPermissions = NewCache(Parser{}, 0)
+
+// newTestEnforcer returns an enforcer resolving every token to principal
+func newTestEnforcer(principal *auth.Principal) *Enforcer {
+	return NewEnforcer(testPermissions, func(ctx context.Context, token string) (*auth.Principal, error) {
+		return principal, nil
+	})
+}
+
+// serve runs a request with a token in its context through middleware guarding an empty handler
+func serve(middleware func(http.Handler) http.Handler, target string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	r = r.WithContext(auth.WithToken(r.Context(), "token"))
+
+	w := httptest.NewRecorder()
+	middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, r)
+	return w
+}
+
+func TestEnforcerInvalidRequirement(t *testing.T) {
+	e := newTestEnforcer(&auth.Principal{Permissions: map[string]string{"*": "allowed"}})
+	const invalid = "any(svc:{read"
+
+	checks := map[string]error{
+		"ValidatePermissions":        e.ValidatePermissions(context.Background(), "token", invalid),
+		"ValidateAccountPermissions": e.ValidateAccountPermissions(context.Background(), "token", "42", invalid),
+	}
+	for name, err := range checks {
+		var authErr *auth.AuthError
+		if !errors.Is(err, ErrInvalidRequirement) || !errors.As(err, &authErr) {
+			t.Fatalf("%s: expected an invalid requirement auth error, got %v", name, err)
+		}
+		if authErr.StatusCode != http.StatusInternalServerError || authErr.Details["required"] != invalid {
+			t.Errorf("%s: expected a 500 naming the requirement, got %d %v", name, authErr.StatusCode, authErr.Details)
+		}
+	}
+
+	if w := serve(e.RequirePermissions(invalid), "/"); w.Code != http.StatusInternalServerError {
+		t.Errorf("expected a misconfigured route to fail with 500, got %d", w.Code)
+	}
+}
+
+func TestEnforcerUnresolvedTemplate(t *testing.T) {
+	e := newTestEnforcer(&auth.Principal{Permissions: map[string]string{"svc:read:*": "allowed"}})
+	middleware := e.RequirePermissions("svc:read:{query.resource}")
+
+	if w := serve(middleware, "/?resource=users"); w.Code != http.StatusOK {
+		t.Errorf("expected a filled template to be granted, got %d", w.Code)
+	}
+	if w := serve(middleware, "/"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected a missing placeholder value to fail with 400, got %d", w.Code)
+	}
+	if w := serve(middleware, "/?resource=*"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected a wildcard placeholder value to fail with 400, got %d", w.Code)
+	}
+}
+
+// accountPrincipal holds roles in accounts A and B and a role for all accounts
+var accountPrincipal = &auth.Principal{
+	Roles: []auth.PrincipalRole{
+		{AccountID: "A", Permissions: map[string]string{"svc:read:*": "allowed"}},
+		{AccountID: "B", Permissions: map[string]string{"svc:write:*": "allowed"}},
+		{AccountID: auth.AllAccounts, Permissions: map[string]string{"svc:list:*": "allowed"}},
+	},
+	Permissions: map[string]string{"svc:read:*": "allowed", "svc:write:*": "allowed", "svc:list:*": "allowed"},
+}
+
+func TestPrincipalPermissions(t *testing.T) {
+	tests := []struct {
+		name      string
+		accountID string
+		granted   []string
+		refused   []string
+	}{
+		{
+			name:    "every account",
+			granted: []string{"svc:read:users", "svc:write:users", "svc:list:users"},
+		},
+		{
+			name:      "account A",
+			accountID: "A",
+			granted:   []string{"svc:read:users", "svc:list:users"},
+			refused:   []string{"svc:write:users"},
+		},
+		{
+			name:      "account B",
+			accountID: "B",
+			granted:   []string{"svc:write:users", "svc:list:users"},
+			refused:   []string{"svc:read:users"},
+		},
+		{
+			name:      "account without roles",
+			accountID: "C",
+			granted:   []string{"svc:list:users"},
+			refused:   []string{"svc:read:users", "svc:write:users"},
+		},
+		{
+			name:      "all accounts marker",
+			accountID: auth.AllAccounts,
+			granted:   []string{"svc:list:users"},
+			refused:   []string{"svc:read:users", "svc:write:users"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := (Parser{}).PrincipalPermissions(accountPrincipal, tt.accountID)
+			check := func(perm string) error {
+				required, err := Parse(perm)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return set.Check(required)
+			}
+
+			for _, perm := range tt.granted {
+				if err := check(perm); err != nil {
+					t.Errorf("expected %s to be granted, got %v", perm, err)
+				}
+			}
+			for _, perm := range tt.refused {
+				if err := check(perm); !errors.Is(err, auth.ErrInsufficientPermissions) {
+					t.Errorf("expected %s to be refused, got %v", perm, err)
+				}
+			}
+		})
+	}
+
+	// Accounts without roles share the all-accounts set rather than growing the principal
+	parser := Parser{}
+	if parser.PrincipalPermissions(accountPrincipal, "C") != parser.PrincipalPermissions(accountPrincipal, "D") {
+		t.Error("expected accounts without roles to share a set")
+	}
+}
+
+func TestEnforcerAccountPermissions(t *testing.T) {
+	e := newTestEnforcer(accountPrincipal)
+	middleware := e.RequireAccountPermissions("svc:read:users", auth.AccountFromHeader("X-Account"))
+
+	tests := []struct {
+		accountID string
+		wantCode  int
+	}{
+		{"A", http.StatusOK},
+		{"B", http.StatusForbidden},
+		{"C", http.StatusForbidden},
+		{auth.AllAccounts, http.StatusForbidden},
+		{"", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("account %q", tt.accountID), func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("X-Account", tt.accountID)
+			r = r.WithContext(auth.WithToken(r.Context(), "token"))
+
+			w := httptest.NewRecorder()
+			middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, r)
+			if w.Code != tt.wantCode {
+				t.Errorf("expected %d, got %d", tt.wantCode, w.Code)
+			}
+		})
+	}
+
+	if err := e.ValidateAccountPermissions(context.Background(), "token", "B", "svc:write:users"); err != nil {
+		t.Errorf("expected account B to grant svc:write:users, got %v", err)
+	}
+	if err := e.ValidateAccountPermissions(context.Background(), "token", "A", "svc:write:users"); !errors.Is(err, auth.ErrInsufficientPermissions) {
+		t.Errorf("expected account A to refuse svc:write:users, got %v", err)
+	}
+}
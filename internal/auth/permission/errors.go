@@ -0,0 +1,44 @@
+package permission
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+)
+
+// ErrInvalidRequirement indicates a required permission expression that doesn't parse
+var ErrInvalidRequirement = errors.New("invalid required permission")
+
+// invalidRequirementError reports a route's required permission that doesn't parse
+// It is a route setup error rather than the caller's fault, so it is an internal error
+func invalidRequirementError(requiredPerm string, err error) error {
+	return auth.NewAuthError(fmt.Errorf("%w: %w", ErrInvalidRequirement, err), "route has an invalid required permission", http.StatusInternalServerError).
+		WithDetail("required", requiredPerm)
+}
+
+// unresolvedRequirementError reports a permission template the request didn't supply values for
+func unresolvedRequirementError(requiredPerm string, err error) error {
+	return auth.NewAuthError(err, "required permission could not be filled from the request", http.StatusBadRequest).
+		WithDetail("required", requiredPerm)
+}
+
+// permissionDeniedError reports a requirement vetoed by an explicitly denied permission
+func permissionDeniedError(required fmt.Stringer, denied string) error {
+	return auth.NewAuthError(auth.ErrPermissionDenied, "denied by "+denied, http.StatusForbidden).
+		WithDetail("required_permission", required.String()).
+		WithDetail("denied_permission", denied)
+}
+
+// insufficientPermissionsError reports a requirement no allowed permission satisfies
+func insufficientPermissionsError(required fmt.Stringer) error {
+	return auth.NewAuthError(auth.ErrInsufficientPermissions, "required "+required.String(), http.StatusForbidden).
+		WithDetail("required_permission", required.String())
+}
+
+// excludedPermissionError reports a not() requirement whose operand is granted
+func excludedPermissionError(excluded fmt.Stringer) error {
+	return auth.NewAuthError(auth.ErrInsufficientPermissions, "excluded "+excluded.String(), http.StatusForbidden).
+		WithDetail("excluded_permission", excluded.String())
+}
@@ -0,0 +1,160 @@
+package permission
+
+import (
+	"sort"
+
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+)
+
+// Permission check outcomes reported by Explain
+const (
+	OutcomeGranted      = "granted"
+	OutcomeDenied       = "denied"
+	OutcomeInsufficient = "insufficient"
+)
+
+// Explanation is the evaluation trace of a permission check, see Explain
+type Explanation struct {
+	// Required is the permission that was checked
+	Required string `json:"required"`
+
+	// Outcome is granted, denied or insufficient
+	Outcome string `json:"outcome"`
+
+	// Denials are the denied permissions matching the required permission, and any that
+	// couldn't be parsed, in evaluation order
+	Denials []DenialTrace `json:"denials"`
+
+	// Grants are the allowed permissions matching the required permission, most specific first
+	Grants []PermissionTrace `json:"grants"`
+
+	// DeniedBy is the denial that decided the check, if any
+	DeniedBy *DenialTrace `json:"denied_by,omitempty"`
+
+	// GrantedBy is the allowed permission that granted access, if any, the one Set.Match reports
+	GrantedBy *PermissionTrace `json:"granted_by,omitempty"`
+}
+
+// PermissionTrace is a permission taking part in a check and the roles it came from
+type PermissionTrace struct {
+	Permission string   `json:"permission"`
+	Roles      []string `json:"roles"`
+}
+
+// DenialTrace is a matching denied permission and the specificity comparison made against it
+type DenialTrace struct {
+	PermissionTrace
+
+	// RequiredMoreSpecific is whether the required permission is more specific than the
+	// denial, see isMoreSpecificThan. A more specific requirement escapes the denial.
+	RequiredMoreSpecific bool `json:"required_more_specific"`
+
+	// Applied is whether the denial took effect
+	Applied bool `json:"applied"`
+
+	// Unparseable is whether the denial couldn't be parsed, which denies every check
+	Unparseable bool `json:"unparseable,omitempty"`
+}
+
+// Explain explains a permission check with the default section limit, see Parser.Explain
+func Explain(requiredPerm *Permission, permissions map[string]string, roles []auth.PrincipalRole) *Explanation {
+	return Parser{}.Explain(requiredPerm, permissions, roles)
+}
+
+// Explain evaluates a permission check like Check and returns the full trace
+// permissions are the permissions the check runs against; roles are only used to report
+// which role each permission came from
+func (ps Parser) Explain(requiredPerm *Permission, permissions map[string]string, roles []auth.PrincipalRole) *Explanation {
+	e := &Explanation{
+		Required: requiredPerm.String(),
+		Denials:  []DenialTrace{},
+		Grants:   []PermissionTrace{},
+	}
+
+	// Explicit denials, in a stable order so the trace is reproducible
+	var allowedPerms []*Permission
+	for _, permStr := range sortedKeys(permissions) {
+		perm, err := ps.Parse(permStr)
+		if err != nil {
+			// Compile fails closed on denials it can't parse
+			if permissions[permStr] == "denied" {
+				e.Denials = append(e.Denials, DenialTrace{
+					PermissionTrace: traceOf(permStr, "denied", roles),
+					Applied:         true,
+					Unparseable:     true,
+				})
+			}
+			continue
+		}
+		if !perm.Matches(requiredPerm) {
+			continue
+		}
+
+		switch permissions[permStr] {
+		case "denied":
+			moreSpecific := requiredPerm.isMoreSpecificThan(perm)
+			e.Denials = append(e.Denials, DenialTrace{
+				PermissionTrace:      traceOf(permStr, "denied", roles),
+				RequiredMoreSpecific: moreSpecific,
+				Applied:              !moreSpecific,
+			})
+		case "allowed":
+			allowedPerms = append(allowedPerms, perm)
+		}
+	}
+
+	for i := range e.Denials {
+		if e.Denials[i].Applied {
+			e.DeniedBy = &e.Denials[i]
+			e.Outcome = OutcomeDenied
+			return e
+		}
+	}
+
+	// Matching grants, most specific first with ties broken as Set.Match breaks them, so the
+	// first is the grant Match reports
+	sort.Slice(allowedPerms, func(i, j int) bool {
+		return preferred(allowedPerms[i], allowedPerms[j])
+	})
+	for _, perm := range allowedPerms {
+		e.Grants = append(e.Grants, traceOf(perm.String(), "allowed", roles))
+	}
+
+	if len(e.Grants) > 0 {
+		e.GrantedBy = &e.Grants[0]
+		e.Outcome = OutcomeGranted
+		return e
+	}
+
+	e.Outcome = OutcomeInsufficient
+	return e
+}
+
+// traceOf finds the roles granting a permission with the given status
+func traceOf(permStr, status string, roles []auth.PrincipalRole) PermissionTrace {
+	trace := PermissionTrace{Permission: permStr, Roles: []string{}}
+	for _, role := range roles {
+		if role.Permissions[permStr] == status {
+			trace.Roles = append(trace.Roles, roleName(role))
+		}
+	}
+	return trace
+}
+
+// roleName describes a role by name, falling back to its ID
+func roleName(role auth.PrincipalRole) string {
+	if role.Name != "" {
+		return role.Name
+	}
+	return role.ID
+}
+
+// sortedKeys returns the keys of a permission map in sorted order
+func sortedKeys(permissions map[string]string) []string {
+	keys := make([]string, 0, len(permissions))
+	for k := range permissions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,67 @@
+package permission
+
+import "testing"
+
+func TestExplainGrantedByMatchesSet(t *testing.T) {
+	tests := []struct {
+		name        string
+		required    string
+		permissions map[string]string
+	}{
+		{
+			name:        "equally specific grants",
+			required:    "svc:read:users",
+			permissions: map[string]string{"svc:read:*": "allowed", "svc:*:users": "allowed", "*": "allowed"},
+		},
+		{
+			name:        "literal over glob and alternation",
+			required:    "svc:read:report-daily",
+			permissions: map[string]string{"svc:read:report-*": "allowed", "svc:{read,list}:report-daily": "allowed", "svc:read:report-daily": "allowed"},
+		},
+		{
+			name:        "glob and alternation",
+			required:    "svc:read:report-daily",
+			permissions: map[string]string{"svc:read:report-*": "allowed", "svc:{read,list}:*": "allowed", "svc:*:report-daily": "allowed"},
+		},
+		{
+			name:        "longer grants",
+			required:    "svc:read",
+			permissions: map[string]string{"svc:read:*": "allowed", "svc:*:users": "allowed", "svc": "allowed"},
+		},
+		{
+			name:        "wildcard forms",
+			required:    "svc:read",
+			permissions: map[string]string{"*": "allowed", "": "allowed", ":": "allowed", "*:*": "allowed"},
+		},
+		{
+			name:        "escaped denial",
+			required:    "svc:delete:users",
+			permissions: map[string]string{"svc:delete:*": "denied", "svc:*:users": "allowed", "svc:delete:{users,groups}": "allowed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			required, err := Parse(tt.required)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Explanations are built from sorted map keys, so repeat to catch order dependence
+			for i := 0; i < 10; i++ {
+				want, err := Compile(tt.permissions).Match(required)
+				if err != nil {
+					t.Fatalf("Match: %v", err)
+				}
+
+				explanation := Explain(required, tt.permissions, nil)
+				if explanation.Outcome != OutcomeGranted || explanation.GrantedBy == nil {
+					t.Fatalf("expected a granted explanation, got %+v", explanation)
+				}
+				if explanation.GrantedBy.Permission != want.String() {
+					t.Fatalf("explanation granted by %s, Match reports %s", explanation.GrantedBy.Permission, want)
+				}
+			}
+		})
+	}
+}
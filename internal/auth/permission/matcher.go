@@ -0,0 +1,281 @@
+package permission
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+	"github.com/jcsawyer123/simple-go-api/internal/logger"
+	"github.com/jcsawyer123/simple-go-api/internal/metrics"
+)
+
+// unparseable counts held permissions Compile couldn't parse
+// The counter is created on first use, once the global metrics reporter is set up
+var unparseable struct {
+	once    sync.Once
+	counter metrics.Counter
+}
+
+// Set is a set of allowed and denied permissions indexed for matching
+//
+// Permissions are parsed once and stored in a trie with one level per section. Literal sections
+// are indexed by value, "*" and empty sections share a wildcard edge, and globs and alternations
+// are kept as pattern edges. A check walks only the edges compatible with the required permission,
+// so its cost depends on the shape of the required permission rather than the size of the set.
+// Each node also records the most specific permission below it, so a required permission whose
+// remaining sections are all "*" is decided at that node instead of walking the whole subtree.
+// A Set is immutable and safe for concurrent use.
+type Set struct {
+	allowed permissionNode
+	denied  permissionNode
+
+	// unparseableDenial is a denied permission that couldn't be parsed, so the set can't tell
+	// what it denies and every check fails closed
+	unparseableDenial string
+}
+
+// permissionNode is a trie node
+type permissionNode struct {
+	children map[string]*permissionNode // Edges for literal sections
+	patterns []patternEdge              // Edges for glob and alternation sections
+	wildcard *permissionNode            // Edge for "*" and empty sections
+	perms    []*Permission              // Permissions ending here, their further sections match anything
+	top      *Permission                // Most specific permission here or below, see preferred
+}
+
+// patternEdge is a trie edge for a section matching more than one value
+type patternEdge struct {
+	section string
+	pattern *sectionPattern
+	node    *permissionNode
+}
+
+// Compile compiles permissions with the default section limit, see Parser.Compile
+func Compile(permissions map[string]string) *Set {
+	return Parser{}.Compile(permissions)
+}
+
+// Compile builds a permission set from a map of permission strings to "allowed" or "denied"
+//
+// Other statuses are ignored. Unparseable permissions are logged and counted; an unparseable
+// allowed permission grants nothing, but an unparseable denied permission denies every check,
+// since a narrower denial silently dropped would let a broader grant through.
+func (ps Parser) Compile(permissions map[string]string) *Set {
+	s := &Set{}
+	for permStr, status := range permissions {
+		var root *permissionNode
+		switch status {
+		case "allowed":
+			root = &s.allowed
+		case "denied":
+			root = &s.denied
+		default:
+			continue
+		}
+
+		perm, err := ps.Parse(permStr)
+		if err != nil {
+			unparseable.once.Do(func() {
+				unparseable.counter = metrics.CounterMetric("aims_unparseable_permissions_total", nil)
+			})
+			unparseable.counter.Inc()
+			logger.Warnf("Ignoring unparseable %s permission %q: %v", status, permStr, err)
+
+			// Keep the first in sorted order so checks report the same denial every time
+			if status == "denied" && (s.unparseableDenial == "" || permStr < s.unparseableDenial) {
+				s.unparseableDenial = permStr
+			}
+			continue
+		}
+		root.insert(perm)
+	}
+	return s
+}
+
+// PrincipalPermissions returns the principal's compiled permissions, within accountID if set
+// The set is compiled on first use and kept with the principal, see auth.Principal.Compiled.
+// Sets are kept per section limit, so parsers with different limits never share one.
+func (ps Parser) PrincipalPermissions(principal *auth.Principal, accountID string) *Set {
+	prefix := "permission:" + strconv.Itoa(ps.maxSections()) + ":"
+	key := prefix + "all"
+	if accountID != "" {
+		// Accounts without roles of their own only see the all-accounts roles, so they share
+		// one set and callers can't grow the principal by naming arbitrary accounts
+		key = prefix + "account:" + auth.AllAccounts
+		for i := range principal.Roles {
+			if principal.Roles[i].AccountID == accountID {
+				key = prefix + "account:" + accountID
+				break
+			}
+		}
+	}
+
+	return principal.Compiled(key, func() interface{} {
+		if accountID == "" {
+			return ps.Compile(principal.Permissions)
+		}
+		return ps.Compile(principal.AccountPermissions(accountID))
+	}).(*Set)
+}
+
+// Check checks if the set grants the required permission, with the same result as the package level Check
+func (s *Set) Check(requiredPerm *Permission) error {
+	if err := s.failClosed(requiredPerm); err != nil {
+		return err
+	}
+	if denied := s.denied.find(requiredPerm, 0, deniedFor); denied != nil {
+		return permissionDeniedError(requiredPerm, denied.String())
+	}
+	if s.allowed.find(requiredPerm, 0, allowedFor) != nil {
+		return nil
+	}
+	return insufficientPermissionsError(requiredPerm)
+}
+
+// Match is like Check but also returns the permission that decided the check: the denied
+// permission if denied, otherwise the most specific allowed permission
+// If the set fails closed there is no parsed permission to return, see Compile
+func (s *Set) Match(requiredPerm *Permission) (*Permission, error) {
+	if err := s.failClosed(requiredPerm); err != nil {
+		return nil, err
+	}
+	if denied := s.denied.find(requiredPerm, 0, deniedFor); denied != nil {
+		return denied, permissionDeniedError(requiredPerm, denied.String())
+	}
+
+	var best *Permission
+	s.allowed.find(requiredPerm, 0, func(perm, _ *Permission) bool {
+		if best == nil || preferred(perm, best) {
+			best = perm
+		}
+		return false
+	})
+	if best == nil {
+		return nil, insufficientPermissionsError(requiredPerm)
+	}
+	return best, nil
+}
+
+// failClosed denies the requirement if the set holds an unparseable denied permission
+func (s *Set) failClosed(required fmt.Stringer) error {
+	if s.unparseableDenial == "" {
+		return nil
+	}
+	return permissionDeniedError(required, s.unparseableDenial)
+}
+
+// allowedFor reports whether a matching allowed permission grants the required permission
+func allowedFor(_, _ *Permission) bool {
+	return true
+}
+
+// deniedFor reports whether a matching denied permission applies to the required permission
+// A required permission more specific than the denial escapes it
+func deniedFor(denied, required *Permission) bool {
+	return !required.isMoreSpecificThan(denied)
+}
+
+// preferred reports whether a is more specific than b, breaking ties by the permission string
+// so that the order is total
+func preferred(a, b *Permission) bool {
+	return a.isMoreSpecificThan(b) || (!b.isMoreSpecificThan(a) && a.String() < b.String())
+}
+
+// insert adds a permission below the node
+func (n *permissionNode) insert(perm *Permission) {
+	for i := 0; ; i++ {
+		if n.top == nil || preferred(perm, n.top) {
+			n.top = perm
+		}
+		if i == perm.UsedSections {
+			break
+		}
+		n = n.child(perm.Sections[i], perm.section(i))
+	}
+	n.perms = append(n.perms, perm)
+}
+
+// child returns the node below the edge for a section, adding it if needed
+func (n *permissionNode) child(section string, pattern *sectionPattern) *permissionNode {
+	if pattern.any {
+		if n.wildcard == nil {
+			n.wildcard = &permissionNode{}
+		}
+		return n.wildcard
+	}
+
+	if value, ok := pattern.literal(); ok {
+		if n.children == nil {
+			n.children = make(map[string]*permissionNode)
+		}
+		child, ok := n.children[value]
+		if !ok {
+			child = &permissionNode{}
+			n.children[value] = child
+		}
+		return child
+	}
+
+	for _, edge := range n.patterns {
+		if edge.section == section {
+			return edge.node
+		}
+	}
+	child := &permissionNode{}
+	n.patterns = append(n.patterns, patternEdge{section: section, pattern: pattern, node: child})
+	return child
+}
+
+// find returns a permission matching required, see Permission.Matches, for which accept
+// returns true, or nil if there is none
+//
+// Once the remaining required sections are all "*", every permission below the node matches and
+// only the node's most specific permission is offered to accept. accept must therefore hold for
+// a permission whenever it holds for a less specific one, as allowedFor and deniedFor do.
+func (n *permissionNode) find(required *Permission, depth int, accept func(perm, required *Permission) bool) *Permission {
+	if required.anyFrom(depth) {
+		if n.top != nil && accept(n.top, required) {
+			return n.top
+		}
+		return nil
+	}
+
+	for _, perm := range n.perms {
+		if accept(perm, required) {
+			return perm
+		}
+	}
+
+	if n.wildcard != nil {
+		if perm := n.wildcard.find(required, depth+1, accept); perm != nil {
+			return perm
+		}
+	}
+
+	section := required.section(depth)
+	for _, edge := range n.patterns {
+		if edge.pattern.overlaps(section) {
+			if perm := edge.node.find(required, depth+1, accept); perm != nil {
+				return perm
+			}
+		}
+	}
+
+	if value, ok := section.literal(); ok {
+		if child, ok := n.children[value]; ok {
+			return child.find(required, depth+1, accept)
+		}
+		return nil
+	}
+
+	// Wildcards, globs and alternations in the required permission match many values
+	for value, child := range n.children {
+		if section.matchesValue(value) {
+			if perm := child.find(required, depth+1, accept); perm != nil {
+				return perm
+			}
+		}
+	}
+	return nil
+}
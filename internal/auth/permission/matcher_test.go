@@ -0,0 +1,192 @@
+package permission
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+)
+
+// benchmarkPermissions returns a principal's worth of permissions: 1000 literal grants across
+// 20 services plus wildcard grants and denials
+func benchmarkPermissions() map[string]string {
+	permissions := make(map[string]string)
+	actions := []string{"read", "write", "list", "create", "delete"}
+	for svc := 0; svc < 20; svc++ {
+		for _, action := range actions {
+			for res := 0; res < 10; res++ {
+				permissions[fmt.Sprintf("svc%d:%s:res%d", svc, action, res)] = "allowed"
+			}
+		}
+	}
+	permissions["reports:*"] = "allowed"
+	permissions["audit:{read,list}:*"] = "allowed"
+	permissions["svc3:delete:*"] = "denied"
+	permissions["svc5:write:secret"] = "denied"
+	return permissions
+}
+
+func BenchmarkPermissionSetCheck(b *testing.B) {
+	set := Compile(benchmarkPermissions())
+
+	benchmarks := []struct {
+		name     string
+		required string
+		granted  bool
+	}{
+		{name: "granted", required: "svc7:read:res3", granted: true},
+		{name: "granted wildcard grant", required: "reports:read:monthly", granted: true},
+		{name: "granted alternation grant", required: "audit:list:events", granted: true},
+		{name: "denied", required: "svc5:write:secret"},
+		{name: "denied wildcard", required: "svc3:delete:*"},
+		{name: "insufficient", required: "billing:read:invoices"},
+		{name: "insufficient deep", required: "svc7:read:res99"},
+		{name: "required wildcard", required: "svc7:*", granted: true},
+		{name: "required wildcard action", required: "svc7:*:res3", granted: true},
+		{name: "required wildcard all", required: "*", granted: false},
+		{name: "required wildcard insufficient", required: "billing:*"},
+	}
+
+	for _, bm := range benchmarks {
+		required, err := Parse(bm.required)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := set.Check(required); (err == nil) != bm.granted {
+			b.Fatalf("%s: unexpected result %v", bm.required, err)
+		}
+
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = set.Check(required)
+			}
+		})
+	}
+}
+
+// naiveCheck evaluates a check directly from Permission.Matches and isMoreSpecificThan, the
+// definition Set.Check must agree with: a matching denial applies unless the
+// required permission is more specific, otherwise any matching grant allows
+func naiveCheck(required *Permission, permissions map[string]string) (best *Permission, err error) {
+	for permStr, status := range permissions {
+		perm, parseErr := Parse(permStr)
+		if parseErr != nil {
+			if status == "denied" {
+				return nil, auth.ErrPermissionDenied
+			}
+			continue
+		}
+		if status == "denied" && perm.Matches(required) && !required.isMoreSpecificThan(perm) {
+			return nil, auth.ErrPermissionDenied
+		}
+	}
+
+	for permStr, status := range permissions {
+		perm, parseErr := Parse(permStr)
+		if parseErr != nil || status != "allowed" || !perm.Matches(required) {
+			continue
+		}
+		if best == nil || preferred(perm, best) {
+			best = perm
+		}
+	}
+	if best == nil {
+		return nil, auth.ErrInsufficientPermissions
+	}
+	return best, nil
+}
+
+func FuzzPermissionSetCheck(f *testing.F) {
+	f.Add("svc:read:users\nsvc:*:admin", uint8(0), "svc:read:users")
+	f.Add("svc:*\nsvc:delete:*", uint8(2), "svc:delete:*")
+	f.Add("svc:*\nsvc:delete:*", uint8(2), "svc:delete:users")
+	f.Add("*\nsvc:{read,list}:*", uint8(2), "svc:list")
+	f.Add("svc:report-*:read\nsvc:*:read", uint8(0), "svc:report-daily:read")
+	f.Add("svc:{a,b}:*\nsvc:a*", uint8(1), "svc:{b,c}")
+	f.Add("*\nsvc:{read", uint8(2), "svc:read:users")
+	f.Add("*\nsvc:re*d:x", uint8(2), "*")
+	f.Add("svc:\n:x\nsvc::y", uint8(4), "svc:x:y")
+
+	f.Fuzz(func(t *testing.T, perms string, denyMask uint8, requiredStr string) {
+		required, err := Parse(requiredStr)
+		if err != nil {
+			return
+		}
+
+		permissions := make(map[string]string)
+		for i, permStr := range strings.SplitN(perms, "\n", 8) {
+			permissions[permStr] = "allowed"
+			if denyMask&(1<<i) != 0 {
+				permissions[permStr] = "denied"
+			}
+		}
+
+		want, wantErr := naiveCheck(required, permissions)
+		set := Compile(permissions)
+
+		for _, sentinel := range []error{auth.ErrPermissionDenied, auth.ErrInsufficientPermissions} {
+			if err := set.Check(required); errors.Is(err, sentinel) != errors.Is(wantErr, sentinel) {
+				t.Fatalf("Check(%q) against %q = %v, want %v", requiredStr, permissions, err, wantErr)
+			}
+		}
+
+		got, err := set.Match(required)
+		if (err == nil) != (wantErr == nil) {
+			t.Fatalf("Match(%q) against %q = %v, want %v", requiredStr, permissions, err, wantErr)
+		}
+		if err == nil && got.String() != want.String() {
+			t.Fatalf("Match(%q) against %q = %s, want the most specific grant %s", requiredStr, permissions, got, want)
+		}
+	})
+}
+
+func TestCompilePermissionsFailsClosed(t *testing.T) {
+	tests := []struct {
+		name   string
+		denial string
+	}{
+		{name: "unbalanced brace", denial: "svc:{delete"},
+		{name: "nested braces", denial: "svc:{delete,{purge}}"},
+		{name: "mid-section wildcard", denial: "svc:de*ete"},
+		{name: "too many sections", denial: "svc:delete:users:a:b:c"},
+	}
+
+	required, err := Parse("svc:delete:users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	excluded, err := ParseRequirement("not(svc:admin)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := Compile(map[string]string{"*": "allowed", tt.denial: "denied"})
+
+			if err := set.Check(required); !errors.Is(err, auth.ErrPermissionDenied) {
+				t.Errorf("expected unparseable denial to deny, got %v", err)
+			}
+			if _, err := set.Match(required); !errors.Is(err, auth.ErrPermissionDenied) {
+				t.Errorf("expected Match to deny, got %v", err)
+			}
+			if err := excluded.Check(set); !errors.Is(err, auth.ErrPermissionDenied) {
+				t.Errorf("expected not() to deny, got %v", err)
+			}
+
+			explanation := Explain(required, map[string]string{"*": "allowed", tt.denial: "denied"}, nil)
+			if explanation.Outcome != OutcomeDenied || explanation.DeniedBy == nil || !explanation.DeniedBy.Unparseable {
+				t.Errorf("expected explanation to be denied by the unparseable denial, got %+v", explanation)
+			}
+		})
+	}
+
+	// An unparseable grant grants nothing but doesn't deny
+	set := Compile(map[string]string{"svc:{delete": "allowed", "svc:delete:users": "allowed"})
+	if err := set.Check(required); err != nil {
+		t.Errorf("expected unparseable grant to be ignored, got %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package permission
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// DefaultMaxSections is the maximum number of sections in a permission string unless a
+	// Parser sets another limit
+	DefaultMaxSections = 5
+
+	// Wildcard represents the wildcard permission symbol
+	Wildcard = "*"
+)
+
+// Parser parses permissions and requirement expressions
+// The zero value accepts at most DefaultMaxSections sections.
+type Parser struct {
+	// MaxSections is the maximum number of sections in a permission, DefaultMaxSections if not positive
+	MaxSections int
+}
+
+// maxSections returns the section limit the parser applies
+func (ps Parser) maxSections() int {
+	if ps.MaxSections <= 0 {
+		return DefaultMaxSections
+	}
+	return ps.MaxSections
+}
+
+// Permission represents a structured permission with sections
+type Permission struct {
+	Sections     []string
+	UsedSections int
+	original     string
+	patterns     []sectionPattern // Parsed form of each section
+}
+
+// Parse parses a permission with the default section limit, see Parser.Parse
+func Parse(perm string) (*Permission, error) {
+	return Parser{}.Parse(perm)
+}
+
+// Parse converts a permission string into a structured Permission
+//
+// The grammar is
+//
+//	permission   = "*" | section *( ":" section )
+//	section      = "" | "*" | pattern | alternation | placeholder
+//	alternation  = "{" pattern 1*( "," pattern ) "}"
+//	placeholder  = "{" literal "}"
+//	pattern      = literal | literal "*"
+//	literal      = 1*( any character except ":", "{", "}", "*" )
+//
+// with at most Parser.MaxSections sections. Empty and "*" sections match any value,
+// "report-*" matches values starting with "report-", and {managed,unmanaged} matches either
+// alternative. Placeholders are matched literally until filled, see Permission.Resolve.
+// Sections missing from the end of a permission match any value.
+func (ps Parser) Parse(perm string) (*Permission, error) {
+	if perm == Wildcard {
+		return &Permission{
+			Sections:     []string{Wildcard},
+			UsedSections: 1,
+			original:     Wildcard,
+			patterns:     []sectionPattern{anySection},
+		}, nil
+	}
+
+	parts := strings.Split(perm, ":")
+	if limit := ps.maxSections(); len(parts) > limit {
+		return nil, fmt.Errorf("invalid permission format (too many parts, at most %d): %s", limit, perm)
+	}
+
+	p := &Permission{
+		Sections:     parts,
+		UsedSections: len(parts),
+		original:     perm,
+		patterns:     make([]sectionPattern, len(parts)),
+	}
+
+	for i, part := range parts {
+		pattern, err := parseSection(part)
+		if err != nil {
+			return nil, &SectionError{Permission: perm, Index: i, Section: part, Reason: err.Error()}
+		}
+		p.patterns[i] = pattern
+	}
+
+	return p, nil
+}
+
+// String returns the string representation of the permission
+func (p *Permission) String() string {
+	if p.original != "" {
+		return p.original
+	}
+
+	// Build string only up to used sections
+	return strings.Join(p.Sections[:p.UsedSections], ":")
+}
+
+// section returns the parsed section at index i; sections past the end match any value
+func (p *Permission) section(i int) *sectionPattern {
+	if i >= p.UsedSections {
+		return &anySection
+	}
+	return &p.patterns[i]
+}
+
+// anyFrom reports whether every section from index i on matches any value
+func (p *Permission) anyFrom(i int) bool {
+	for ; i < p.UsedSections; i++ {
+		if !p.patterns[i].any {
+			return false
+		}
+	}
+	return true
+}
+
+// isMoreSpecificThan checks if this permission is more specific than the other permission
+func (p *Permission) isMoreSpecificThan(other *Permission) bool {
+	// If other is "*", this is always more specific
+	if other.UsedSections == 1 && other.Sections[0] == Wildcard {
+		return p.UsedSections > 1 || p.Sections[0] != Wildcard
+	}
+
+	// If used sections are different, more sections is more specific
+	if p.UsedSections != other.UsedSections {
+		return p.UsedSections > other.UsedSections
+	}
+
+	// Otherwise compare how narrowly the sections match, see sectionPattern.specificity
+	pScore, oScore := 0, 0
+	for i := 0; i < p.UsedSections; i++ {
+		pScore += p.section(i).specificity()
+		oScore += other.section(i).specificity()
+	}
+	return pScore > oScore
+}
+
+// Matches checks if this permission matches the required permission
+// Permissions match if every pair of sections has a value in common
+func (p *Permission) Matches(required *Permission) bool {
+	// Fast path for exact matches
+	if p.original == required.original {
+		return true
+	}
+
+	// Fast path for single "*"
+	if (p.UsedSections == 1 && p.Sections[0] == Wildcard) ||
+		(required.UsedSections == 1 && required.Sections[0] == Wildcard) {
+		return true
+	}
+
+	// Compare sections
+	maxSections := p.UsedSections
+	if required.UsedSections > maxSections {
+		maxSections = required.UsedSections
+	}
+
+	for i := 0; i < maxSections; i++ {
+		if !p.section(i).overlaps(required.section(i)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Check checks if any of the user's permissions match the required permission
+// Permissions checked repeatedly should be compiled once with Compile instead
+func Check(requiredPerm *Permission, permissions map[string]string) error {
+	return Compile(permissions).Check(requiredPerm)
+}
@@ -0,0 +1,84 @@
+package permission
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+)
+
+func FuzzParsePermission(f *testing.F) {
+	for _, seed := range []string{
+		"*",
+		"",
+		"iam:read:users",
+		"myservice:*:update",
+		"myservice:managed:update:*",
+		"reports:report-*:read",
+		"myservice:{managed,unmanaged}:update",
+		"myservice:{accountID}:update:{resource}",
+		"a::b",
+		"a:b:c:d:e:f",
+		"a:{b",
+		"a:re*port",
+		"a:{b,{c}}",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		p, err := Parse(s)
+		if err != nil {
+			return
+		}
+
+		str := p.String()
+		q, err := Parse(str)
+		if err != nil {
+			t.Fatalf("%q parsed but its string %q doesn't: %v", s, str, err)
+		}
+		if q.String() != str {
+			t.Fatalf("%q: string %q reparsed as %q", s, str, q.String())
+		}
+		if q.UsedSections != p.UsedSections || !reflect.DeepEqual(q.Sections, p.Sections) || !reflect.DeepEqual(q.patterns, p.patterns) {
+			t.Fatalf("%q: reparsing %q gave a different permission: %+v, want %+v", s, str, q, p)
+		}
+
+		// A permission always matches itself and is never more specific than itself
+		if !p.Matches(p) {
+			t.Fatalf("%q doesn't match itself", s)
+		}
+		if p.isMoreSpecificThan(p) {
+			t.Fatalf("%q is more specific than itself", s)
+		}
+	})
+}
+
+func TestParserMaxSections(t *testing.T) {
+	const long = "svc:a:b:c:d:e:f"
+
+	if _, err := Parse(long); err == nil {
+		t.Fatalf("expected %q to exceed the default limit of %d sections", long, DefaultMaxSections)
+	}
+
+	parser := Parser{MaxSections: 7}
+	if _, err := parser.Parse(long); err != nil {
+		t.Fatalf("expected %q to parse with a limit of 7 sections: %v", long, err)
+	}
+	if _, err := parser.ParseRequirement("any(" + long + ", svc:read)"); err != nil {
+		t.Fatalf("expected the limit to apply within requirements: %v", err)
+	}
+
+	// Sets compiled for a principal are kept per limit
+	principal := &auth.Principal{Permissions: map[string]string{long: "allowed"}}
+	required, err := parser.Parse(long)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (Parser{}).PrincipalPermissions(principal, "").Check(required); err == nil {
+		t.Fatal("expected the default limit to ignore the long grant")
+	}
+	if err := parser.PrincipalPermissions(principal, "").Check(required); err != nil {
+		t.Fatalf("expected the long grant to be honoured with a limit of 7 sections, got %v", err)
+	}
+}
@@ -0,0 +1,332 @@
+package permission
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+)
+
+// Requirement operator names used by ParseRequirement
+const (
+	opAnyOf = "any"
+	opAllOf = "all"
+	opNot   = "not"
+)
+
+// Requirement is a permission requirement expression evaluated against a set of permissions
+//
+// The string syntax accepted by ParseRequirement is either a single permission, or one of
+//   - any(r1, r2, ...)  satisfied if at least one requirement is satisfied
+//   - all(r1, r2, ...)  satisfied if every requirement is satisfied
+//   - not(r)            satisfied if the requirement is not satisfied
+//
+// Expressions nest, e.g. all(myservice:*:read, any(myservice:*:export, myservice:*:admin)).
+// Commas inside a permission's {a,b} alternation don't separate operands.
+// An explicit denial of any permission outside a not() vetoes the whole expression, and a set
+// holding an unparseable denial fails every expression, see Compile.
+type Requirement interface {
+	// Check returns nil if the permissions satisfy the requirement
+	Check(permissions *Set) error
+
+	// Resolve fills any permission template placeholders from the request
+	Resolve(r *http.Request) (Requirement, error)
+
+	// String returns the expression form of the requirement
+	String() string
+}
+
+// CheckRequirement checks if the user's permissions satisfy a requirement expression
+// Permissions checked repeatedly should be compiled once and passed to Requirement.Check instead
+func CheckRequirement(req Requirement, permissions map[string]string) error {
+	return req.Check(Compile(permissions))
+}
+
+// Require returns a requirement satisfied by a single permission
+func Require(p *Permission) Requirement {
+	return permissionRequirement{perm: p}
+}
+
+// AnyOf returns a requirement satisfied if at least one of the requirements is satisfied
+func AnyOf(reqs ...Requirement) Requirement {
+	return anyOf(reqs)
+}
+
+// AllOf returns a requirement satisfied if every one of the requirements is satisfied
+func AllOf(reqs ...Requirement) Requirement {
+	return allOf(reqs)
+}
+
+// Not returns a requirement satisfied if the requirement is not satisfied
+func Not(req Requirement) Requirement {
+	return not{req: req}
+}
+
+type permissionRequirement struct {
+	perm *Permission
+}
+
+func (p permissionRequirement) Check(permissions *Set) error {
+	return permissions.Check(p.perm)
+}
+
+func (p permissionRequirement) Resolve(r *http.Request) (Requirement, error) {
+	resolved, err := p.perm.Resolve(r)
+	if err != nil {
+		return nil, err
+	}
+	return permissionRequirement{perm: resolved}, nil
+}
+
+func (p permissionRequirement) String() string {
+	return p.perm.String()
+}
+
+type anyOf []Requirement
+
+func (a anyOf) Check(permissions *Set) error {
+	granted := false
+	for _, req := range a {
+		err := req.Check(permissions)
+		if err == nil {
+			granted = true
+			continue
+		}
+
+		// Denied takes precedence over any other grant
+		if errors.Is(err, auth.ErrPermissionDenied) {
+			return err
+		}
+	}
+
+	if granted {
+		return nil
+	}
+	return insufficientPermissionsError(a)
+}
+
+func (a anyOf) Resolve(r *http.Request) (Requirement, error) {
+	reqs, err := resolveAll(a, r)
+	return anyOf(reqs), err
+}
+
+func (a anyOf) String() string {
+	return formatRequirements(opAnyOf, a)
+}
+
+type allOf []Requirement
+
+func (a allOf) Check(permissions *Set) error {
+	var firstErr error
+	for _, req := range a {
+		err := req.Check(permissions)
+		if err == nil {
+			continue
+		}
+
+		// Denied takes precedence over a missing grant
+		if errors.Is(err, auth.ErrPermissionDenied) {
+			return err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (a allOf) Resolve(r *http.Request) (Requirement, error) {
+	reqs, err := resolveAll(a, r)
+	return allOf(reqs), err
+}
+
+func (a allOf) String() string {
+	return formatRequirements(opAllOf, a)
+}
+
+type not struct {
+	req Requirement
+}
+
+func (n not) Check(permissions *Set) error {
+	// A set that fails closed denies everything, it doesn't lack the excluded permission
+	if err := permissions.failClosed(n); err != nil {
+		return err
+	}
+	if err := n.req.Check(permissions); err != nil {
+		return nil
+	}
+	return excludedPermissionError(n.req)
+}
+
+func (n not) Resolve(r *http.Request) (Requirement, error) {
+	req, err := n.req.Resolve(r)
+	if err != nil {
+		return nil, err
+	}
+	return not{req: req}, nil
+}
+
+func (n not) String() string {
+	return opNot + "(" + n.req.String() + ")"
+}
+
+// resolveAll resolves each requirement in a list
+func resolveAll(reqs []Requirement, r *http.Request) ([]Requirement, error) {
+	resolved := make([]Requirement, len(reqs))
+	for i, req := range reqs {
+		res, err := req.Resolve(r)
+		if err != nil {
+			return nil, err
+		}
+		resolved[i] = res
+	}
+	return resolved, nil
+}
+
+// formatRequirements formats an operator and its operands, e.g. any(a:b, c:d)
+func formatRequirements(op string, reqs []Requirement) string {
+	parts := make([]string, len(reqs))
+	for i, req := range reqs {
+		parts[i] = req.String()
+	}
+	return op + "(" + strings.Join(parts, ", ") + ")"
+}
+
+// Matched returns the allowed permissions that satisfy a requirement, the most specific
+// grant for a single permission, the first satisfied operand of an any() and every operand of an
+// all(). A not() is satisfied by the absence of a grant, so it contributes nothing.
+func Matched(req Requirement, permissions *Set) []string {
+	switch req := req.(type) {
+	case permissionRequirement:
+		if granted, err := permissions.Match(req.perm); err == nil {
+			return []string{granted.String()}
+		}
+	case anyOf:
+		for _, operand := range req {
+			if operand.Check(permissions) == nil {
+				return Matched(operand, permissions)
+			}
+		}
+	case allOf:
+		var matched []string
+		for _, operand := range req {
+			matched = append(matched, Matched(operand, permissions)...)
+		}
+		return matched
+	}
+	return nil
+}
+
+// ParseRequirement parses a requirement expression with the default section limit, see
+// Parser.ParseRequirement
+func ParseRequirement(expr string) (Requirement, error) {
+	return Parser{}.ParseRequirement(expr)
+}
+
+// ParseRequirement parses a requirement expression, see Requirement for the syntax
+func (ps Parser) ParseRequirement(expr string) (Requirement, error) {
+	return parseRequirement(expr, ps.Parse)
+}
+
+// parseRequirement parses a requirement expression using parsePerm for each permission
+func parseRequirement(expr string, parsePerm func(string) (*Permission, error)) (Requirement, error) {
+	p := &requirementParser{input: expr, parsePerm: parsePerm}
+
+	req, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if p.pos != len(p.input) {
+		return nil, fmt.Errorf("invalid requirement (unexpected %q at %d): %s", p.input[p.pos], p.pos, expr)
+	}
+	return req, nil
+}
+
+// requirementParser is a recursive descent parser for requirement expressions
+type requirementParser struct {
+	input     string
+	pos       int
+	parsePerm func(string) (*Permission, error)
+}
+
+func (p *requirementParser) parseExpr() (Requirement, error) {
+	p.skipSpace()
+
+	for _, op := range []string{opAnyOf, opAllOf, opNot} {
+		if strings.HasPrefix(p.input[p.pos:], op+"(") {
+			p.pos += len(op) + 1
+			return p.parseOperator(op)
+		}
+	}
+
+	// Otherwise a single permission, running up to the next delimiter outside an alternation
+	start := p.pos
+	inBraces := false
+	for p.pos < len(p.input) && (inBraces || !strings.ContainsRune("(),", rune(p.input[p.pos]))) {
+		switch p.input[p.pos] {
+		case '{':
+			inBraces = true
+		case '}':
+			inBraces = false
+		}
+		p.pos++
+	}
+
+	permStr := strings.TrimSpace(p.input[start:p.pos])
+	if permStr == "" {
+		return nil, fmt.Errorf("invalid requirement (missing permission at %d): %s", start, p.input)
+	}
+
+	perm, err := p.parsePerm(permStr)
+	if err != nil {
+		return nil, err
+	}
+	return Require(perm), nil
+}
+
+func (p *requirementParser) parseOperator(op string) (Requirement, error) {
+	var operands []Requirement
+	for {
+		req, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, req)
+
+		p.skipSpace()
+		if p.pos >= len(p.input) {
+			return nil, fmt.Errorf("invalid requirement (unterminated %s): %s", op, p.input)
+		}
+		if p.input[p.pos] == ')' {
+			p.pos++
+			break
+		}
+		if p.input[p.pos] != ',' {
+			return nil, fmt.Errorf("invalid requirement (unexpected %q at %d): %s", p.input[p.pos], p.pos, p.input)
+		}
+		p.pos++
+	}
+
+	switch op {
+	case opAnyOf:
+		return AnyOf(operands...), nil
+	case opAllOf:
+		return AllOf(operands...), nil
+	default:
+		if len(operands) != 1 {
+			return nil, fmt.Errorf("invalid requirement (not takes exactly one operand): %s", p.input)
+		}
+		return Not(operands[0]), nil
+	}
+}
+
+func (p *requirementParser) skipSpace() {
+	for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t') {
+		p.pos++
+	}
+}
@@ -0,0 +1,168 @@
+package permission
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Section specificity scores, see sectionPattern.specificity
+const (
+	specificityAny = iota
+	specificityPrefix
+	specificityAlternation
+	specificityLiteral
+)
+
+// SectionError reports a permission section that doesn't follow the grammar, see Parse
+type SectionError struct {
+	Permission string
+	Index      int // Zero-based index of the section
+	Section    string
+	Reason     string
+}
+
+func (e *SectionError) Error() string {
+	return fmt.Sprintf("invalid permission format (section %d %q: %s): %s", e.Index+1, e.Section, e.Reason, e.Permission)
+}
+
+// sectionPattern is the parsed form of a permission section
+// A section matches a value if it matches any value, or the value is one of values or starts
+// with one of prefixes
+type sectionPattern struct {
+	any      bool
+	values   []string
+	prefixes []string
+}
+
+// anySection is the pattern of "*" and empty sections
+var anySection = sectionPattern{any: true}
+
+// parseSection parses a single section of a permission
+func parseSection(section string) (sectionPattern, error) {
+	if section == "" || section == Wildcard {
+		return anySection, nil
+	}
+
+	if strings.HasPrefix(section, "{") && strings.HasSuffix(section, "}") && len(section) > 2 {
+		inner := section[1 : len(section)-1]
+		if !strings.Contains(inner, ",") {
+			// A template placeholder, matched literally until it is filled
+			if strings.ContainsAny(inner, "{}*") {
+				return sectionPattern{}, errors.New("placeholder names may not contain '{', '}' or '*'")
+			}
+			return sectionPattern{values: []string{section}}, nil
+		}
+
+		var pattern sectionPattern
+		for _, alt := range strings.Split(inner, ",") {
+			if alt == Wildcard {
+				return sectionPattern{}, errors.New("alternatives may not be '*', use '*' for the whole section")
+			}
+			if err := pattern.add(alt); err != nil {
+				return sectionPattern{}, err
+			}
+		}
+		return pattern, nil
+	}
+
+	if strings.ContainsAny(section, "{}") {
+		return sectionPattern{}, errors.New("'{' and '}' may only enclose a whole section")
+	}
+
+	var pattern sectionPattern
+	if err := pattern.add(section); err != nil {
+		return sectionPattern{}, err
+	}
+	return pattern, nil
+}
+
+// add adds a literal or prefix glob to the pattern
+func (s *sectionPattern) add(pattern string) error {
+	switch {
+	case pattern == "":
+		return errors.New("alternatives may not be empty")
+	case strings.ContainsAny(pattern, "{}"):
+		return errors.New("alternations may not be nested")
+	case strings.HasSuffix(pattern, Wildcard):
+		prefix := strings.TrimSuffix(pattern, Wildcard)
+		if strings.Contains(prefix, Wildcard) {
+			return errors.New("'*' may only end a section")
+		}
+		s.prefixes = append(s.prefixes, prefix)
+	case strings.Contains(pattern, Wildcard):
+		return errors.New("'*' may only end a section")
+	default:
+		s.values = append(s.values, pattern)
+	}
+	return nil
+}
+
+// literal returns the value of a section matching exactly one value
+func (s *sectionPattern) literal() (string, bool) {
+	if s.any || len(s.prefixes) > 0 || len(s.values) != 1 {
+		return "", false
+	}
+	return s.values[0], true
+}
+
+// matchesValue reports whether the section matches a value
+func (s *sectionPattern) matchesValue(value string) bool {
+	if s.any {
+		return true
+	}
+	for _, v := range s.values {
+		if v == value {
+			return true
+		}
+	}
+	for _, prefix := range s.prefixes {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// overlaps reports whether some value matches both sections
+func (s *sectionPattern) overlaps(other *sectionPattern) bool {
+	if s.any || other.any {
+		return true
+	}
+
+	for _, v := range s.values {
+		if other.matchesValue(v) {
+			return true
+		}
+	}
+	for _, v := range other.values {
+		if s.matchesValue(v) {
+			return true
+		}
+	}
+
+	// Two globs share values if one prefix extends the other
+	for _, p := range s.prefixes {
+		for _, o := range other.prefixes {
+			if strings.HasPrefix(p, o) || strings.HasPrefix(o, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// specificity scores how narrowly the section matches: a single literal over a set of
+// literals, over a prefix glob, over any value
+func (s *sectionPattern) specificity() int {
+	switch {
+	case s.any:
+		return specificityAny
+	case len(s.prefixes) > 0:
+		return specificityPrefix
+	case len(s.values) > 1:
+		return specificityAlternation
+	default:
+		return specificityLiteral
+	}
+}
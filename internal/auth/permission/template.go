@@ -0,0 +1,91 @@
+package permission
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+)
+
+const (
+	// placeholderQueryPrefix marks a placeholder filled from a query parameter, e.g. {query.resource}
+	placeholderQueryPrefix = "query."
+
+	// placeholderHeaderPrefix marks a placeholder filled from a request header, e.g. {header.X-Resource}
+	placeholderHeaderPrefix = "header."
+)
+
+// ErrUnresolvedPlaceholder indicates a permission template could not be filled from the request
+var ErrUnresolvedPlaceholder = fmt.Errorf("%w: unresolved permission placeholder", auth.ErrInvalidRequest)
+
+// IsTemplate reports whether the permission contains request-derived placeholders
+//
+// Placeholders occupy a whole section, name a single value and are filled at request time:
+//   - {name}         chi URL parameter
+//   - {query.name}   query parameter
+//   - {header.Name}  request header
+//
+// e.g. myservice:{accountID}:update:{query.resource}
+func (p *Permission) IsTemplate() bool {
+	for i := 0; i < p.UsedSections; i++ {
+		if isPlaceholder(p.Sections[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns a copy of the permission template with every placeholder section
+// filled from the request. Permissions without placeholders are returned unchanged.
+func (p *Permission) Resolve(r *http.Request) (*Permission, error) {
+	if !p.IsTemplate() {
+		return p, nil
+	}
+
+	resolved := &Permission{
+		Sections:     append([]string(nil), p.Sections...),
+		UsedSections: p.UsedSections,
+		patterns:     append([]sectionPattern(nil), p.patterns...),
+	}
+
+	for i := 0; i < p.UsedSections; i++ {
+		section := p.Sections[i]
+		if !isPlaceholder(section) {
+			continue
+		}
+
+		value := placeholderValue(r, section[1:len(section)-1])
+
+		// Values come from the caller, so they must not be able to widen the permission
+		// or shift it into different sections
+		if value == "" || strings.ContainsAny(value, ":*{}") {
+			return nil, fmt.Errorf("%w: %s", ErrUnresolvedPlaceholder, section)
+		}
+		resolved.Sections[i] = value
+		resolved.patterns[i] = sectionPattern{values: []string{value}}
+	}
+
+	resolved.original = resolved.String()
+	return resolved, nil
+}
+
+// isPlaceholder reports whether a section is a {placeholder}
+// Braces around a comma separated list are an alternation instead, see Parse
+func isPlaceholder(section string) bool {
+	return len(section) > 2 && strings.HasPrefix(section, "{") && strings.HasSuffix(section, "}") &&
+		!strings.Contains(section, ",")
+}
+
+// placeholderValue looks up the value of a placeholder name in the request
+func placeholderValue(r *http.Request, name string) string {
+	switch {
+	case strings.HasPrefix(name, placeholderQueryPrefix):
+		return r.URL.Query().Get(strings.TrimPrefix(name, placeholderQueryPrefix))
+	case strings.HasPrefix(name, placeholderHeaderPrefix):
+		return r.Header.Get(strings.TrimPrefix(name, placeholderHeaderPrefix))
+	default:
+		return chi.URLParam(r, name)
+	}
+}
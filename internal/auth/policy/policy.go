@@ -0,0 +1,232 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+	"github.com/jcsawyer123/simple-go-api/internal/auth/permission"
+)
+
+// File is the on-disk format of a route policy file
+//
+//	{
+//	  "policies": [
+//	    {"method": "GET", "route": "/api/data", "authenticated": true},
+//	    {"method": "GET", "route": "/api/any/test", "require": "any(myservice:managed:update:*, myservice:*:admin)"},
+//	    {"method": "GET", "route": "/api/accounts/{accountID}/perms/test", "require": "myservice:managed:update:*", "account": "param:accountID"}
+//	  ]
+//	}
+//
+// route is the full chi route pattern. require is a requirement expression, see
+// permission.Requirement, and authenticated marks routes open to any authenticated caller;
+// each policy sets exactly one of them. account makes the check account-scoped, with
+// the account read from a URL parameter (param:NAME) or header (header:NAME).
+type File struct {
+	Policies []Policy `json:"policies"`
+}
+
+// Policy binds a route to a permission requirement
+type Policy struct {
+	Method        string `json:"method"`
+	Route         string `json:"route"`
+	Require       string `json:"require"`
+	Account       string `json:"account"`
+	Authenticated bool   `json:"authenticated"`
+
+	resolveAccount auth.AccountResolver
+}
+
+// Set is a validated set of route policies
+type Set struct {
+	policies map[string]*Policy // keyed by routeKey
+}
+
+// Load reads and validates a policy file, parsing requirements with parser
+func Load(path string, parser permission.Parser) (*Set, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading policy file: %w", err)
+	}
+
+	var file File
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing policy file %s: %w", path, err)
+	}
+
+	set, err := NewSet(file.Policies, parser)
+	if err != nil {
+		return nil, fmt.Errorf("policy file %s: %w", path, err)
+	}
+	return set, nil
+}
+
+// NewSet validates policies, parsing requirements with parser, and builds a policy set
+func NewSet(policies []Policy, parser permission.Parser) (*Set, error) {
+	s := &Set{policies: make(map[string]*Policy, len(policies))}
+
+	var errs []error
+	for i := range policies {
+		p := policies[i]
+		p.Method = strings.ToUpper(p.Method)
+
+		if err := p.validate(parser); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		key := routeKey(p.Method, p.Route)
+		if _, exists := s.policies[key]; exists {
+			errs = append(errs, fmt.Errorf("%s: duplicate policy", key))
+			continue
+		}
+		s.policies[key] = &p
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return s, nil
+}
+
+// validate checks a policy and prepares its account resolver
+func (p *Policy) validate(parser permission.Parser) error {
+	key := routeKey(p.Method, p.Route)
+
+	if p.Method == "" || !strings.HasPrefix(p.Route, "/") {
+		return fmt.Errorf("%s: method and an absolute route are required", key)
+	}
+	if (p.Require == "") == !p.Authenticated {
+		return fmt.Errorf("%s: set exactly one of require or authenticated", key)
+	}
+
+	if p.Require != "" {
+		if _, err := parser.ParseRequirement(p.Require); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+
+	if p.Account != "" {
+		if p.Authenticated {
+			return fmt.Errorf("%s: account requires a permission requirement", key)
+		}
+
+		source, name, _ := strings.Cut(p.Account, ":")
+		switch {
+		case name == "":
+			return fmt.Errorf("%s: invalid account source %q", key, p.Account)
+		case source == "param":
+			p.resolveAccount = auth.AccountFromURLParam(name)
+		case source == "header":
+			p.resolveAccount = auth.AccountFromHeader(name)
+		default:
+			return fmt.Errorf("%s: invalid account source %q", key, p.Account)
+		}
+	}
+	return nil
+}
+
+// Validate checks the policies against the routes registered on a router
+// Every policy must point at a registered route, and every route under one of the
+// protected prefixes must have a policy
+func (s *Set) Validate(routes chi.Routes, protectedPrefixes ...string) error {
+	registered := make(map[string]bool)
+	var errs []error
+
+	err := chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		key := routeKey(method, route)
+		registered[key] = true
+
+		if _, ok := s.policies[key]; !ok && isProtected(route, protectedPrefixes) {
+			errs = append(errs, fmt.Errorf("%s: route has no policy", key))
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("walking routes: %w", err)
+	}
+
+	for key := range s.policies {
+		if !registered[key] {
+			errs = append(errs, fmt.Errorf("%s: policy points at a missing route", key))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// isProtected reports whether a route falls under one of the protected prefixes
+func isProtected(route string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if route == prefix || strings.HasPrefix(route, strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// routeKey identifies a route by method and pattern
+func routeKey(method, route string) string {
+	return method + " " + route
+}
+
+// originalRequestKey carries the request the middleware was called with through a policy check
+type originalRequestKey struct{}
+
+// Middleware enforces the policies using the permission checks of an auth middleware
+//
+// It must run after authentication. The route is looked up on router ahead of routing, so
+// URL parameters used by templates and account resolution are available to the checks.
+// Routes with no policy are refused, so a route added without validation fails closed.
+func (s *Set) Middleware(router *chi.Mux, authMiddleware auth.Middleware) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		// Continue with the request as it arrived rather than the copy used for the checks
+		proceed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.Context().Value(originalRequestKey{}).(*http.Request))
+		})
+
+		guards := make(map[string]http.Handler, len(s.policies))
+		for key, p := range s.policies {
+			switch {
+			case p.Authenticated:
+				guards[key] = proceed
+			case p.resolveAccount != nil:
+				guards[key] = authMiddleware.RequireAccountPermissions(p.Require, p.resolveAccount)(proceed)
+			default:
+				guards[key] = authMiddleware.RequirePermissions(p.Require)(proceed)
+			}
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path := r.URL.RawPath
+			if path == "" {
+				path = r.URL.Path
+			}
+
+			rctx := chi.NewRouteContext()
+			route := router.Find(rctx, r.Method, path)
+			if route == "" {
+				// Unknown routes are left to the router's not found handling
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			guard, ok := guards[routeKey(r.Method, route)]
+			if !ok {
+				auth.WriteError(w, r, auth.NewAuthError(auth.ErrInsufficientPermissions, "no policy for route", http.StatusForbidden).
+					WithDetail("route", route))
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), chi.RouteCtxKey, rctx)
+			ctx = context.WithValue(ctx, originalRequestKey{}, r)
+			guard.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+	"github.com/jcsawyer123/simple-go-api/internal/auth/permission"
+)
+
+// checkedKey marks requests that went through a fakeAuth permission check
+type checkedKey struct{}
+
+// check records a permission check made by fakeAuth
+type check struct {
+	required string
+	account  string
+	id       string
+}
+
+// fakeAuth records permission checks instead of enforcing them
+type fakeAuth struct {
+	checks []check
+}
+
+func (f *fakeAuth) Authenticate(next http.Handler) http.Handler { return next }
+
+func (f *fakeAuth) AuthenticateWith(...auth.TokenExtractor) func(http.Handler) http.Handler {
+	return f.Authenticate
+}
+
+func (f *fakeAuth) RequirePermissions(requiredPerm string) func(http.Handler) http.Handler {
+	return f.RequireAccountPermissions(requiredPerm, nil)
+}
+
+func (f *fakeAuth) RequireAccountPermissions(requiredPerm string, resolve auth.AccountResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c := check{required: requiredPerm, id: chi.URLParam(r, "id")}
+			if resolve != nil {
+				c.account = resolve(r)
+			}
+			f.checks = append(f.checks, c)
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), checkedKey{}, true)))
+		})
+	}
+}
+
+// newTestRouter registers the test routes behind policies enforced by a fakeAuth
+func newTestRouter(t *testing.T, policies []Policy) (*chi.Mux, *Set, *fakeAuth) {
+	t.Helper()
+
+	set, err := NewSet(policies, permission.Parser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeAuth{}
+	router := chi.NewRouter()
+	router.Route("/api", func(r chi.Router) {
+		r.Use(set.Middleware(router, f))
+
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			// Handlers must see the request as it arrived, routed by the router itself
+			if r.Context().Value(checkedKey{}) != nil {
+				t.Error("handler received the request used for the policy check")
+			}
+			w.Write([]byte(chi.URLParam(r, "id")))
+		}
+		r.Get("/data", handler)
+		r.Get("/items/{id}", handler)
+		r.Get("/accounts/{accountID}/items/{id}", handler)
+		r.Get("/unlisted", handler)
+	})
+	return router, set, f
+}
+
+func TestNewSetValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		wantErr string
+	}{
+		{
+			name:   "require",
+			policy: Policy{Method: "get", Route: "/api/data", Require: "svc:read:*"},
+		},
+		{
+			name:   "authenticated",
+			policy: Policy{Method: "GET", Route: "/api/data", Authenticated: true},
+		},
+		{
+			name:   "account from header",
+			policy: Policy{Method: "GET", Route: "/api/data", Require: "svc:read:*", Account: "header:X-Account"},
+		},
+		{
+			name:    "neither require nor authenticated",
+			policy:  Policy{Method: "GET", Route: "/api/data"},
+			wantErr: "set exactly one of require or authenticated",
+		},
+		{
+			name:    "both require and authenticated",
+			policy:  Policy{Method: "GET", Route: "/api/data", Require: "svc:read:*", Authenticated: true},
+			wantErr: "set exactly one of require or authenticated",
+		},
+		{
+			name:    "missing method",
+			policy:  Policy{Route: "/api/data", Authenticated: true},
+			wantErr: "method and an absolute route are required",
+		},
+		{
+			name:    "relative route",
+			policy:  Policy{Method: "GET", Route: "api/data", Authenticated: true},
+			wantErr: "method and an absolute route are required",
+		},
+		{
+			name:    "invalid requirement",
+			policy:  Policy{Method: "GET", Route: "/api/data", Require: "any(svc:read"},
+			wantErr: "GET /api/data",
+		},
+		{
+			name:    "account without requirement",
+			policy:  Policy{Method: "GET", Route: "/api/data", Authenticated: true, Account: "param:accountID"},
+			wantErr: "account requires a permission requirement",
+		},
+		{
+			name:    "unknown account source",
+			policy:  Policy{Method: "GET", Route: "/api/data", Require: "svc:read:*", Account: "query:account"},
+			wantErr: "invalid account source",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSet([]Policy{tt.policy}, permission.Parser{})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestNewSetRejectsDuplicates(t *testing.T) {
+	_, err := NewSet([]Policy{
+		{Method: "GET", Route: "/api/data", Authenticated: true},
+		{Method: "get", Route: "/api/data", Require: "svc:read:*"},
+	}, permission.Parser{})
+	if err == nil || !strings.Contains(err.Error(), "duplicate policy") {
+		t.Fatalf("expected a duplicate policy error, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	router, set, _ := newTestRouter(t, []Policy{
+		{Method: "GET", Route: "/api/data", Authenticated: true},
+		{Method: "GET", Route: "/api/items/{id}", Require: "svc:read:{param.id}"},
+		{Method: "GET", Route: "/api/accounts/{accountID}/items/{id}", Require: "svc:read:*", Account: "param:accountID"},
+		{Method: "GET", Route: "/api/gone", Authenticated: true},
+	})
+
+	err := set.Validate(router, "/api")
+	if err == nil {
+		t.Fatal("expected validation to fail")
+	}
+	for _, want := range []string{
+		"GET /api/unlisted: route has no policy",
+		"GET /api/gone: policy points at a missing route",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "/api/data") {
+		t.Errorf("did not expect a covered route to be reported: %v", err)
+	}
+
+	// Routes outside the protected prefixes don't need a policy
+	if err := set.Validate(router, "/internal"); err == nil || strings.Contains(err.Error(), "route has no policy") {
+		t.Errorf("expected only the missing route to be reported, got %v", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	router, _, f := newTestRouter(t, []Policy{
+		{Method: "GET", Route: "/api/data", Authenticated: true},
+		{Method: "GET", Route: "/api/items/{id}", Require: "svc:read:{param.id}"},
+		{Method: "GET", Route: "/api/accounts/{accountID}/items/{id}", Require: "svc:read:*", Account: "param:accountID"},
+	})
+
+	tests := []struct {
+		name      string
+		target    string
+		wantCode  int
+		wantBody  string
+		wantCheck *check
+	}{
+		{
+			name:     "authenticated route",
+			target:   "/api/data",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:      "url params reach the check and the handler",
+			target:    "/api/items/users",
+			wantCode:  http.StatusOK,
+			wantBody:  "users",
+			wantCheck: &check{required: "svc:read:{param.id}", id: "users"},
+		},
+		{
+			name:      "account resolved from the route",
+			target:    "/api/accounts/42/items/users",
+			wantCode:  http.StatusOK,
+			wantBody:  "users",
+			wantCheck: &check{required: "svc:read:*", account: "42", id: "users"},
+		},
+		{
+			name:     "unknown route left to the router",
+			target:   "/api/missing",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f.checks = nil
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d", tt.wantCode, w.Code)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("expected body %q, got %q", tt.wantBody, w.Body.String())
+			}
+
+			switch {
+			case tt.wantCheck == nil && len(f.checks) != 0:
+				t.Errorf("expected no permission check, got %+v", f.checks)
+			case tt.wantCheck != nil && (len(f.checks) != 1 || f.checks[0] != *tt.wantCheck):
+				t.Errorf("expected check %+v, got %+v", *tt.wantCheck, f.checks)
+			}
+		})
+	}
+}
+
+func TestMiddlewareFailsClosed(t *testing.T) {
+	router, _, f := newTestRouter(t, []Policy{
+		{Method: "GET", Route: "/api/data", Authenticated: true},
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/unlisted", nil))
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected a route without a policy to be refused, got %d", w.Code)
+	}
+	if len(f.checks) != 0 {
+		t.Errorf("expected no permission check, got %+v", f.checks)
+	}
+
+	var problem map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem["route"] != "/api/unlisted" || problem["reason"] != "insufficient_permissions" {
+		t.Errorf("expected an insufficient permissions problem naming the route, got %v", problem)
+	}
+}
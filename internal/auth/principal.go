@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// AllAccounts is the role account ID marking a role that applies to every account
+const AllAccounts = "*"
+
+// PrincipalType identifies the kind of caller a principal represents
+type PrincipalType string
+
+const (
+	// PrincipalUser is a human user
+	PrincipalUser PrincipalType = "user"
+	// PrincipalService is a service or machine identity
+	PrincipalService PrincipalType = "service"
+)
+
+// Principal is the authenticated caller of a request
+// Principals are shared between middleware and handlers and must not be modified
+type Principal struct {
+	// ID is the user or service ID
+	ID string
+	// Type is the kind of caller
+	Type PrincipalType
+	// Name is a human readable name for the caller
+	Name string
+	// AccountID is the account the caller belongs to
+	AccountID string
+	// Roles are the roles held by the caller
+	Roles []PrincipalRole
+	// Permissions are the permissions merged across all roles
+	Permissions map[string]string
+	// ExpiresAt is when the credentials expire, zero if unknown
+	ExpiresAt time.Time
+	// AuthMethod is the auth service that authenticated the caller
+	AuthMethod string
+
+	// compiled holds structures derived from the permissions, see Compiled
+	compiled sync.Map
+}
+
+// PrincipalRole is a role held by a principal
+type PrincipalRole struct {
+	ID          string
+	Name        string
+	AccountID   string
+	Permissions map[string]string
+}
+
+// AppliesToAccount reports whether the role grants permissions within the account
+func (r *PrincipalRole) AppliesToAccount(accountID string) bool {
+	return r.AccountID == AllAccounts || r.AccountID == accountID
+}
+
+// AccountPermissions merges the permissions of the roles bound to the account or to all accounts
+func (p *Principal) AccountPermissions(accountID string) map[string]string {
+	permissions := make(map[string]string)
+	for i := range p.Roles {
+		if p.Roles[i].AppliesToAccount(accountID) {
+			MergePermissions(permissions, p.Roles[i].Permissions)
+		}
+	}
+	return permissions
+}
+
+// AccountRoles returns the roles bound to the account or to all accounts
+func (p *Principal) AccountRoles(accountID string) []PrincipalRole {
+	var roles []PrincipalRole
+	for i := range p.Roles {
+		if p.Roles[i].AppliesToAccount(accountID) {
+			roles = append(roles, p.Roles[i])
+		}
+	}
+	return roles
+}
+
+// Compiled returns the value stored under key, building and storing it on first use
+//
+// Authorizers use it to index the permissions once per principal rather than on every check.
+// Principals are shared through the token caches, so the value lives as long as the cache entry.
+// Values must not be modified once built.
+func (p *Principal) Compiled(key string, build func() interface{}) interface{} {
+	if value, ok := p.compiled.Load(key); ok {
+		return value
+	}
+
+	// Concurrent first uses may both build, but only one value is kept
+	value, _ := p.compiled.LoadOrStore(key, build())
+	return value
+}
+
+// MergePermissions merges src into dst
+// In case of conflicts, denied takes precedence
+func MergePermissions(dst, src map[string]string) {
+	for permStr, status := range src {
+		if existing, exists := dst[permStr]; !exists || existing != "denied" {
+			dst[permStr] = status
+		}
+	}
+}
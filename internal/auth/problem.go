@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details responses
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details document describing an auth failure
+type Problem struct {
+	Type      string
+	Title     string
+	Status    int
+	Detail    string
+	Instance  string
+	RequestID string
+	// Reason is a stable machine readable code for the failure, e.g. invalid_token
+	Reason string
+	// Extensions are additional members, such as the required permission
+	Extensions map[string]interface{}
+}
+
+// problemReasons maps sentinel errors onto their status code and reason code
+var problemReasons = []struct {
+	err    error
+	status int
+	reason string
+}{
+	{ErrMissingToken, http.StatusUnauthorized, "missing_token"},
+	{ErrExpiredToken, http.StatusUnauthorized, "expired_token"},
+	{ErrInvalidToken, http.StatusUnauthorized, "invalid_token"},
+	{ErrServiceUnavailable, http.StatusServiceUnavailable, "service_unavailable"},
+	{ErrPermissionDenied, http.StatusForbidden, "permission_denied"},
+	{ErrInsufficientPermissions, http.StatusForbidden, "insufficient_permissions"},
+	{ErrInvalidRequest, http.StatusBadRequest, "invalid_request"},
+}
+
+// NewProblem builds the problem details for an error
+//
+// AuthError status codes, messages and details take precedence; other errors are
+// classified by the sentinel errors they wrap. Unclassified errors are reported as
+// internal errors without exposing their message.
+func NewProblem(r *http.Request, err error) *Problem {
+	p := &Problem{
+		Type:       "about:blank",
+		Status:     http.StatusInternalServerError,
+		Reason:     "internal_error",
+		Extensions: make(map[string]interface{}),
+	}
+
+	for _, pr := range problemReasons {
+		if errors.Is(err, pr.err) {
+			p.Status = pr.status
+			p.Reason = pr.reason
+			p.Detail = pr.err.Error()
+			break
+		}
+	}
+
+	var authErr *AuthError
+	if errors.As(err, &authErr) {
+		if authErr.StatusCode != 0 {
+			p.Status = authErr.StatusCode
+		}
+		if authErr.Message != "" {
+			p.Detail = authErr.Message
+		}
+		for k, v := range authErr.Details {
+			p.Extensions[k] = v
+		}
+	}
+
+	p.Title = http.StatusText(p.Status)
+	if r != nil {
+		p.Instance = r.URL.Path
+		p.RequestID = middleware.GetReqID(r.Context())
+	}
+	return p
+}
+
+// MarshalJSON flattens the problem and its extensions into a single object
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	doc := make(map[string]interface{}, len(p.Extensions)+7)
+	for k, v := range p.Extensions {
+		doc[k] = v
+	}
+
+	doc["type"] = p.Type
+	doc["title"] = p.Title
+	doc["status"] = p.Status
+	doc["reason"] = p.Reason
+	if p.Detail != "" {
+		doc["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		doc["instance"] = p.Instance
+	}
+	if p.RequestID != "" {
+		doc["request_id"] = p.RequestID
+	}
+	return json.Marshal(doc)
+}
+
+// WriteProblem writes the problem as an application/problem+json response
+func WriteProblem(w http.ResponseWriter, p *Problem) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		http.Error(w, http.StatusText(p.Status), p.Status)
+		return
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	w.Write(body)
+}
+
+// WriteError writes an error as an application/problem+json response
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	WriteProblem(w, NewProblem(r, err))
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+)
+
+// Authorizer enforces permission requirements on authenticated requests
+type Authorizer interface {
+	RequirePermissions(requiredPerm string) func(http.Handler) http.Handler
+	RequireAccountPermissions(requiredPerm string, resolve AccountResolver) func(http.Handler) http.Handler
+}
+
+// TokenValidator validates a token and returns the principal it identifies
+type TokenValidator func(ctx context.Context, token string) (*Principal, error)
+
+// TokenProvider is a Provider for services that authenticate a bearer-style token
+// read from the request and leave permission checks to an Authorizer
+type TokenProvider struct {
+	credential string
+	validate   TokenValidator
+	authorizer Authorizer
+	extractors []TokenExtractor
+	accept     func(token string) bool
+}
+
+// Ensure TokenProvider implements the Provider interface
+var _ Provider = (*TokenProvider)(nil)
+
+// TokenProviderOption configures a TokenProvider
+type TokenProviderOption func(*TokenProvider)
+
+// WithTokenExtractors sets the ordered list of locations the token is read from
+func WithTokenExtractors(extractors ...TokenExtractor) TokenProviderOption {
+	return func(p *TokenProvider) {
+		p.extractors = extractors
+	}
+}
+
+// WithTokenFilter treats tokens that accept rejects as missing, so a chain moves
+// on to the next provider instead of failing the request
+func WithTokenFilter(accept func(token string) bool) TokenProviderOption {
+	return func(p *TokenProvider) {
+		p.accept = accept
+	}
+}
+
+// NewTokenProvider creates a provider that reads tokens using the default extractors
+// and validates them with validate. credential names the token in errors, e.g. "API key".
+func NewTokenProvider(credential string, validate TokenValidator, authorizer Authorizer, defaults []TokenExtractor, opts ...TokenProviderOption) *TokenProvider {
+	p := &TokenProvider{
+		credential: credential,
+		validate:   validate,
+		authorizer: authorizer,
+		extractors: defaults,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Authenticate implements the Middleware interface
+func (p *TokenProvider) Authenticate(next http.Handler) http.Handler {
+	return p.AuthenticateWith()(next)
+}
+
+// AuthenticateWith implements the Middleware interface
+func (p *TokenProvider) AuthenticateWith(extractors ...TokenExtractor) func(http.Handler) http.Handler {
+	return AuthenticateWith(p, extractors)
+}
+
+// AuthenticateRequest implements the Authenticator interface
+func (p *TokenProvider) AuthenticateRequest(r *http.Request, extractors []TokenExtractor) (context.Context, error) {
+	if len(extractors) == 0 {
+		extractors = p.extractors
+	}
+
+	token := ExtractToken(r, extractors)
+	if token == "" || (p.accept != nil && !p.accept(token)) {
+		return nil, NewAuthError(ErrMissingToken, "no "+p.credential+" provided", http.StatusUnauthorized)
+	}
+
+	principal, err := p.validate(r.Context(), token)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := WithToken(r.Context(), token)
+	return WithPrincipal(ctx, principal), nil
+}
+
+// RequirePermissions implements the Middleware interface
+func (p *TokenProvider) RequirePermissions(requiredPerm string) func(http.Handler) http.Handler {
+	return p.authorizer.RequirePermissions(requiredPerm)
+}
+
+// RequireAccountPermissions implements the Middleware interface
+func (p *TokenProvider) RequireAccountPermissions(requiredPerm string, resolve AccountResolver) func(http.Handler) http.Handler {
+	return p.authorizer.RequireAccountPermissions(requiredPerm, resolve)
+}
+
+// AuthenticateWith returns middleware that authenticates requests with a, writing
+// failures as problem details. Non-empty extractors override a's token locations.
+func AuthenticateWith(a Authenticator, extractors []TokenExtractor) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, err := a.AuthenticateRequest(r, extractors)
+			if err != nil {
+				WriteError(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// PrincipalForToken returns the principal stored in the context if it was
+// authenticated by authMethod with the same token
+func PrincipalForToken(ctx context.Context, token, authMethod string) (*Principal, bool) {
+	if ctxToken, ok := TokenFromContext(ctx); !ok || ctxToken != token {
+		return nil, false
+	}
+
+	principal, ok := PrincipalFromContext(ctx)
+	if !ok || principal.AuthMethod != authMethod {
+		return nil, false
+	}
+	return principal, true
+}
+
+// RequestPrincipal returns a validator that reuses the principal the request was
+// authenticated as, falling back to validate for any other token
+func RequestPrincipal(authMethod string, validate TokenValidator) TokenValidator {
+	return func(ctx context.Context, token string) (*Principal, error) {
+		if principal, ok := PrincipalForToken(ctx, token, authMethod); ok {
+			return principal, nil
+		}
+		return validate(ctx, token)
+	}
+}
@@ -8,13 +8,17 @@ import (
 
 // Service defines the generic interface for authentication and authorization
 type Service interface {
-	// ValidateToken validates a token against the auth service
-	ValidateToken(ctx context.Context, token string) error
+	// ValidateToken validates a token against the auth service and returns the principal it identifies
+	ValidateToken(ctx context.Context, token string) (*Principal, error)
 
 	// ValidatePermissions checks if the token has the required permission
 	// The permission format and validation logic is implementation-specific
 	ValidatePermissions(ctx context.Context, token, requiredPerm string) error
 
+	// ValidateAccountPermissions checks if the token has the required permission
+	// within the given account, ignoring grants bound to other accounts
+	ValidateAccountPermissions(ctx context.Context, token, accountID, requiredPerm string) error
+
 	// CreateMiddleware returns a middleware for this auth service
 	CreateMiddleware() Middleware
 }
@@ -24,6 +28,14 @@ type Middleware interface {
 	// Authenticate is a middleware that verifies the authentication token
 	Authenticate(next http.Handler) http.Handler
 
+	// AuthenticateWith is like Authenticate but reads the token using the given
+	// extractors instead of the middleware's configured ones
+	AuthenticateWith(extractors ...TokenExtractor) func(http.Handler) http.Handler
+
 	// RequirePermissions is a middleware factory that creates middleware requiring specific permissions
 	RequirePermissions(requiredPerm string) func(http.Handler) http.Handler
+
+	// RequireAccountPermissions is like RequirePermissions but only honours grants
+	// bound to the account resolved from the request
+	RequireAccountPermissions(requiredPerm string, resolve AccountResolver) func(http.Handler) http.Handler
 }
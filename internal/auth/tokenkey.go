@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// tokenKeySecretSize is the size of the generated per-process secret
+const tokenKeySecretSize = 32
+
+// TokenHasher derives cache keys from bearer tokens using a keyed hash, so raw
+// credentials never end up in cache memory, heap dumps, logs or external backends
+type TokenHasher struct {
+	secret []byte
+}
+
+// NewTokenHasher creates a TokenHasher keyed with secret
+// An empty secret generates a random per-process secret
+func NewTokenHasher(secret []byte) (*TokenHasher, error) {
+	if len(secret) == 0 {
+		secret = make([]byte, tokenKeySecretSize)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("generating token key secret: %w", err)
+		}
+	}
+
+	return &TokenHasher{secret: secret}, nil
+}
+
+// Key returns the cache key for a token
+func (h *TokenHasher) Key(token string) string {
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Fingerprint returns a short identifier for a token that is safe to log
+func (h *TokenHasher) Fingerprint(token string) string {
+	return h.Key(token)[:12]
+}
@@ -10,13 +10,27 @@ type BaseServiceConfig struct {
 	Timeout time.Duration
 	// RetryCount is the number of retry attempts
 	RetryCount int
+	// RetryWaitTime is the initial wait between retry attempts
+	RetryWaitTime time.Duration
+	// RetryMaxWaitTime is the maximum wait between retry attempts
+	RetryMaxWaitTime time.Duration
 	// CacheTTL is the time-to-live for cached permissions
 	CacheTTL time.Duration
+	// CacheStaleGrace is how long expired permissions are kept to be served while the auth service is unavailable
+	CacheStaleGrace time.Duration
+	// CacheKeySecret keys the hash used to derive cache keys from tokens, random per process if empty
+	CacheKeySecret string
+	// RejectedCacheTTL is the time-to-live for cached token rejections
+	RejectedCacheTTL time.Duration
+	// RejectedCacheMaxEntries bounds the number of cached token rejections
+	RejectedCacheMaxEntries int
 	// CircuitBreakerMaxRequests is the maximum number of requests allowed when the circuit breaker is half-open
 	CircuitBreakerMaxRequests uint32
 	// CircuitBreakerTimeout is the timeout after which the circuit breaker will transition from open to half-open
 	CircuitBreakerTimeout time.Duration
-
+	// CircuitBreakerMinRequests is the number of requests needed before the circuit breaker can trip
+	CircuitBreakerMinRequests uint32
+	// CircuitBreakerFailureThreshold is the failure ratio at which the circuit breaker trips
 	CircuitBreakerFailureThreshold float64
 }
 
@@ -25,9 +39,15 @@ func DefaultServiceConfig() BaseServiceConfig {
 	return BaseServiceConfig{
 		Timeout:                        5 * time.Second,
 		RetryCount:                     3,
+		RetryWaitTime:                  100 * time.Millisecond,
+		RetryMaxWaitTime:               2 * time.Second,
 		CacheTTL:                       5 * time.Minute,
+		CacheStaleGrace:                1 * time.Minute,
+		RejectedCacheTTL:               30 * time.Second,
+		RejectedCacheMaxEntries:        10000,
 		CircuitBreakerMaxRequests:      3,
 		CircuitBreakerTimeout:          10 * time.Second,
+		CircuitBreakerMinRequests:      3,
 		CircuitBreakerFailureThreshold: 0.6,
 	}
 }
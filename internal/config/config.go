@@ -1,6 +1,12 @@
 package config
 
-import "os"
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Config struct {
 	Port           string
@@ -10,8 +16,147 @@ type Config struct {
 	AWSRegion      string
 	ProfilingPort  string
 
+	// TLS termination configuration
+	TLS TLSConfig
+
+	// Auth service client configuration
+	Auth AuthConfig
+
 	// Metrics configuration
 	Metrics MetricsConfig
+
+	// Authorization audit log configuration
+	Audit AuditConfig
+}
+
+type TLSConfig struct {
+	// Server certificate and key, the server listens over plain HTTP if empty
+	CertFile string
+	KeyFile  string
+
+	// CA bundle client certificates are verified against, client certificates are not requested if empty
+	ClientCAFile string
+
+	// Client certificate policy when a client CA bundle is set: "verify-if-given" or "require"
+	ClientAuth string
+}
+
+type AuthConfig struct {
+	// Request timeout for auth service calls
+	Timeout time.Duration
+
+	// Retry attempts and backoff for auth service calls
+	RetryCount       int
+	RetryWaitTime    time.Duration
+	RetryMaxWaitTime time.Duration
+
+	// Time-to-live for cached permissions
+	CacheTTL time.Duration
+
+	// How long expired permissions may be served while the auth service is unavailable
+	CacheStaleGrace time.Duration
+
+	// Secret keying the hash of tokens used as cache keys, random per process if empty
+	CacheKeySecret string
+
+	// Time-to-live and size bound for cached token rejections
+	RejectedCacheTTL        time.Duration
+	RejectedCacheMaxEntries int
+
+	// Size bound for each of the caches of parsed permissions and requirements shared by all auth providers
+	ParsedPermissionCacheSize int
+
+	// Maximum number of sections in a permission
+	PermissionMaxSections int
+
+	// Ordered auth providers tried for routes using the default chain, e.g. "aims"
+	Providers []string
+
+	// Ordered token sources, e.g. "header:x-aims-auth-token,bearer,cookie:aims_token"
+	TokenSources string
+
+	// Query parameters that may carry tokens, stripped from URLs before logging
+	QueryTokenParams []string
+
+	// API key file, API keys are disabled if empty
+	APIKeysFile string
+
+	// How often the API key file is checked for changes
+	APIKeysReloadInterval time.Duration
+
+	// Ordered API key sources, in the same format as TokenSources
+	APIKeySources string
+
+	// Circuit breaker thresholds
+	CircuitBreakerMaxRequests      uint32
+	CircuitBreakerTimeout          time.Duration
+	CircuitBreakerMinRequests      uint32
+	CircuitBreakerFailureThreshold float64
+
+	// Local JWT validation
+	JWT JWTConfig
+
+	// OAuth2 token introspection
+	Introspection IntrospectionConfig
+
+	// JSON file mapping client certificate identities to permissions, mTLS auth is disabled if empty
+	MTLSIdentitiesFile string
+
+	// JSON file binding routes to permission requirements
+	PolicyFile string
+}
+
+type JWTConfig struct {
+	// JWKS URL or file holding the signing keys, JWTs are disabled if both are empty
+	JWKSURL  string
+	JWKSFile string
+
+	// How often the JWKS is reloaded, and the minimum interval between reloads on unknown key IDs
+	JWKSRefreshInterval    time.Duration
+	JWKSMinRefreshInterval time.Duration
+
+	// Expected iss and aud claims, not checked if empty
+	Issuer   string
+	Audience string
+
+	// Claims holding the caller's permissions and account ID
+	PermissionsClaim string
+	AccountClaim     string
+
+	// Clock skew tolerated when checking exp and nbf
+	Leeway time.Duration
+
+	// Ordered JWT sources, in the same format as AuthConfig.TokenSources
+	TokenSources string
+}
+
+type IntrospectionConfig struct {
+	// RFC 7662 introspection endpoint, introspection is disabled if empty
+	URL string
+
+	// Client credentials used to authenticate to the endpoint
+	ClientID     string
+	ClientSecret string
+
+	// JSON file mapping scopes to permissions, required with URL; unmapped scopes grant nothing
+	ScopeMapFile string
+
+	// Ordered access token sources, in the same format as AuthConfig.TokenSources
+	TokenSources string
+}
+
+type AuditConfig struct {
+	// JSON lines file authorization decisions are recorded to, auditing is disabled if empty
+	File string
+
+	// Size in megabytes at which the file is rotated
+	MaxSizeMB int
+
+	// Number of rotated files to keep
+	MaxBackups int
+
+	// Number of events buffered for writing, further events are dropped while the buffer is full
+	BufferSize int
 }
 
 type MetricsConfig struct {
@@ -64,6 +209,63 @@ func Load() (*Config, error) {
 		"env":     getEnvOrDefault("ENV", "development"),
 	}
 
+	env := &envParser{}
+
+	authConfig := AuthConfig{
+		Timeout:                        env.duration("AUTH_TIMEOUT", 5*time.Second),
+		RetryCount:                     env.int("AUTH_RETRY_COUNT", 3),
+		RetryWaitTime:                  env.duration("AUTH_RETRY_WAIT", 100*time.Millisecond),
+		RetryMaxWaitTime:               env.duration("AUTH_RETRY_MAX_WAIT", 2*time.Second),
+		CacheTTL:                       env.duration("AUTH_CACHE_TTL", 5*time.Minute),
+		CacheStaleGrace:                env.duration("AUTH_CACHE_STALE_GRACE", 1*time.Minute),
+		CacheKeySecret:                 getEnvOrDefault("AUTH_CACHE_KEY_SECRET", ""),
+		RejectedCacheTTL:               env.duration("AUTH_REJECTED_CACHE_TTL", 30*time.Second),
+		RejectedCacheMaxEntries:        env.int("AUTH_REJECTED_CACHE_MAX_ENTRIES", 10000),
+		ParsedPermissionCacheSize:      env.int("AUTH_PARSED_PERMISSION_CACHE_SIZE", 10000),
+		PermissionMaxSections:          env.int("AUTH_PERMISSION_MAX_SECTIONS", 5),
+		Providers:                      getEnvList("AUTH_PROVIDERS", "aims"),
+		TokenSources:                   getEnvOrDefault("AUTH_TOKEN_SOURCES", "header:x-aims-auth-token"),
+		QueryTokenParams:               getEnvList("AUTH_QUERY_TOKEN_PARAMS", "token"),
+		APIKeysFile:                    getEnvOrDefault("AUTH_API_KEYS_FILE", ""),
+		APIKeysReloadInterval:          env.duration("AUTH_API_KEYS_RELOAD_INTERVAL", 30*time.Second),
+		APIKeySources:                  getEnvOrDefault("AUTH_API_KEY_SOURCES", "header:x-api-key"),
+		CircuitBreakerMaxRequests:      uint32(env.int("AUTH_CB_MAX_REQUESTS", 3)),
+		CircuitBreakerTimeout:          env.duration("AUTH_CB_TIMEOUT", 10*time.Second),
+		CircuitBreakerMinRequests:      uint32(env.int("AUTH_CB_MIN_REQUESTS", 3)),
+		CircuitBreakerFailureThreshold: env.float("AUTH_CB_FAILURE_THRESHOLD", 0.6),
+		JWT: JWTConfig{
+			JWKSURL:                getEnvOrDefault("AUTH_JWT_JWKS_URL", ""),
+			JWKSFile:               getEnvOrDefault("AUTH_JWT_JWKS_FILE", ""),
+			JWKSRefreshInterval:    env.duration("AUTH_JWT_JWKS_REFRESH", time.Hour),
+			JWKSMinRefreshInterval: env.duration("AUTH_JWT_JWKS_MIN_REFRESH", time.Minute),
+			Issuer:                 getEnvOrDefault("AUTH_JWT_ISSUER", ""),
+			Audience:               getEnvOrDefault("AUTH_JWT_AUDIENCE", ""),
+			PermissionsClaim:       getEnvOrDefault("AUTH_JWT_PERMISSIONS_CLAIM", "permissions"),
+			AccountClaim:           getEnvOrDefault("AUTH_JWT_ACCOUNT_CLAIM", "account_id"),
+			Leeway:                 env.duration("AUTH_JWT_LEEWAY", 30*time.Second),
+			TokenSources:           getEnvOrDefault("AUTH_JWT_SOURCES", "bearer"),
+		},
+		Introspection: IntrospectionConfig{
+			URL:          getEnvOrDefault("AUTH_INTROSPECTION_URL", ""),
+			ClientID:     getEnvOrDefault("AUTH_INTROSPECTION_CLIENT_ID", ""),
+			ClientSecret: getEnvOrDefault("AUTH_INTROSPECTION_CLIENT_SECRET", ""),
+			ScopeMapFile: getEnvOrDefault("AUTH_INTROSPECTION_SCOPE_MAP", ""),
+			TokenSources: getEnvOrDefault("AUTH_INTROSPECTION_SOURCES", "bearer"),
+		},
+		MTLSIdentitiesFile: getEnvOrDefault("AUTH_MTLS_IDENTITIES_FILE", ""),
+		PolicyFile:         getEnvOrDefault("AUTH_POLICY_FILE", "policy.json"),
+	}
+
+	auditConfig := AuditConfig{
+		File:       getEnvOrDefault("AUDIT_LOG_FILE", ""),
+		MaxSizeMB:  env.int("AUDIT_LOG_MAX_SIZE_MB", 100),
+		MaxBackups: env.int("AUDIT_LOG_MAX_BACKUPS", 5),
+		BufferSize: env.int("AUDIT_BUFFER_SIZE", 4096),
+	}
+	if env.err != nil {
+		return nil, env.err
+	}
+
 	return &Config{
 		Port:           getEnvOrDefault("PORT", "8080"),
 		AuthServiceURL: getEnvOrDefault("AUTH_SERVICE_URL", "https://api.product.dev.alertlogic.com"),
@@ -72,6 +274,15 @@ func Load() (*Config, error) {
 		AWSRegion:      getEnvOrDefault("AWS_REGION", "us-west-2"),
 		ProfilingPort:  getEnvOrDefault("PROFILING_PORT", "6060"),
 
+		TLS: TLSConfig{
+			CertFile:     getEnvOrDefault("TLS_CERT_FILE", ""),
+			KeyFile:      getEnvOrDefault("TLS_KEY_FILE", ""),
+			ClientCAFile: getEnvOrDefault("TLS_CLIENT_CA_FILE", ""),
+			ClientAuth:   getEnvOrDefault("TLS_CLIENT_AUTH", "verify-if-given"),
+		},
+
+		Auth: authConfig,
+
 		Metrics: MetricsConfig{
 			Enabled: datadogEnabled || prometheusEnabled,
 
@@ -89,6 +300,8 @@ func Load() (*Config, error) {
 				DefaultTags: defaultTags,
 			},
 		},
+
+		Audit: auditConfig,
 	}, nil
 }
 
@@ -98,3 +311,67 @@ func getEnvOrDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvList reads a comma separated list, dropping empty items
+func getEnvList(key, defaultValue string) []string {
+	var items []string
+	for _, item := range strings.Split(getEnvOrDefault(key, defaultValue), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// envParser reads typed environment variables, keeping the first parse error
+type envParser struct {
+	err error
+}
+
+func (p *envParser) duration(key string, defaultValue time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		p.fail(key, err)
+		return defaultValue
+	}
+	return d
+}
+
+func (p *envParser) int(key string, defaultValue int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil || i < 0 {
+		p.fail(key, fmt.Errorf("must be a non-negative integer: %q", value))
+		return defaultValue
+	}
+	return i
+}
+
+func (p *envParser) float(key string, defaultValue float64) float64 {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		p.fail(key, err)
+		return defaultValue
+	}
+	return f
+}
+
+func (p *envParser) fail(key string, err error) {
+	if p.err == nil {
+		p.err = fmt.Errorf("invalid %s: %w", key, err)
+	}
+}
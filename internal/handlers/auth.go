@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/jcsawyer123/simple-go-api/internal/auth"
+	"github.com/jcsawyer123/simple-go-api/internal/auth/permission"
+)
+
+// ExplainPermission returns the evaluation trace of a permission check for the caller
+// Query parameters: perm is the permission to check, and the optional account scopes
+// the check to roles bound to that account
+func (h *Handlers) ExplainPermission(w http.ResponseWriter, r *http.Request) {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		auth.WriteError(w, r, auth.NewAuthError(auth.ErrMissingToken, "request not authenticated", http.StatusUnauthorized))
+		return
+	}
+
+	permStr := r.URL.Query().Get("perm")
+	if permStr == "" {
+		auth.WriteError(w, r, auth.NewAuthError(auth.ErrInvalidRequest, "perm query parameter is required", http.StatusBadRequest))
+		return
+	}
+
+	perm, err := h.parser.Parse(permStr)
+	if err != nil {
+		auth.WriteError(w, r, auth.NewAuthError(auth.ErrInvalidRequest, err.Error(), http.StatusBadRequest))
+		return
+	}
+
+	permissions, roles := principal.Permissions, principal.Roles
+	if accountID := r.URL.Query().Get("account"); accountID != "" {
+		permissions, roles = principal.AccountPermissions(accountID), principal.AccountRoles(accountID)
+	}
+
+	h.writeJSON(w, http.StatusOK, h.parser.Explain(perm, permissions, roles))
+}
+
+// MaxPermissionChecks is the most permissions a single batch check may evaluate
+const MaxPermissionChecks = 100
+
+// MeResponse describes the caller
+type MeResponse struct {
+	ID          string            `json:"id"`
+	Type        string            `json:"type"`
+	Name        string            `json:"name"`
+	AccountID   string            `json:"account_id"`
+	AuthMethod  string            `json:"auth_method"`
+	Provider    string            `json:"provider,omitempty"`
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty"`
+	Accounts    []AccountSummary  `json:"accounts"`
+	Roles       []RoleSummary     `json:"roles"`
+	Permissions map[string]string `json:"permissions"`
+}
+
+// AccountSummary is the caller's effective permissions within one account
+type AccountSummary struct {
+	AccountID   string            `json:"account_id"`
+	Permissions map[string]string `json:"permissions"`
+}
+
+// RoleSummary is a role held by the caller
+type RoleSummary struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	AccountID   string            `json:"account_id"`
+	Permissions map[string]string `json:"permissions"`
+}
+
+// Me returns the caller's identity, roles and effective permissions
+func (h *Handlers) Me(w http.ResponseWriter, r *http.Request) {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		auth.WriteError(w, r, auth.NewAuthError(auth.ErrMissingToken, "request not authenticated", http.StatusUnauthorized))
+		return
+	}
+
+	response := MeResponse{
+		ID:          principal.ID,
+		Type:        string(principal.Type),
+		Name:        principal.Name,
+		AccountID:   principal.AccountID,
+		AuthMethod:  principal.AuthMethod,
+		Accounts:    []AccountSummary{},
+		Roles:       make([]RoleSummary, 0, len(principal.Roles)),
+		Permissions: principal.Permissions,
+	}
+	response.Provider, _ = auth.AuthProviderFromContext(r.Context())
+	if !principal.ExpiresAt.IsZero() {
+		response.ExpiresAt = &principal.ExpiresAt
+	}
+
+	accounts := make(map[string]bool)
+	for _, role := range principal.Roles {
+		response.Roles = append(response.Roles, RoleSummary{
+			ID:          role.ID,
+			Name:        role.Name,
+			AccountID:   role.AccountID,
+			Permissions: role.Permissions,
+		})
+
+		// Roles for all accounts are folded into every account below
+		if role.AccountID != "" && role.AccountID != auth.AllAccounts {
+			accounts[role.AccountID] = true
+		}
+	}
+
+	for accountID := range accounts {
+		response.Accounts = append(response.Accounts, AccountSummary{
+			AccountID:   accountID,
+			Permissions: principal.AccountPermissions(accountID),
+		})
+	}
+	sort.Slice(response.Accounts, func(i, j int) bool {
+		return response.Accounts[i].AccountID < response.Accounts[j].AccountID
+	})
+
+	h.writeJSON(w, http.StatusOK, response)
+}
+
+// CheckPermissionsRequest is a batch of permissions to check
+type CheckPermissionsRequest struct {
+	// Permissions are permissions or requirement expressions to evaluate
+	Permissions []string `json:"permissions"`
+
+	// Account optionally scopes the checks to roles bound to an account
+	Account string `json:"account"`
+}
+
+// CheckPermissionsResponse is the outcome of each check, in request order
+type CheckPermissionsResponse struct {
+	Results []PermissionCheckResult `json:"results"`
+}
+
+// PermissionCheckResult is the outcome of checking one permission
+type PermissionCheckResult struct {
+	Permission string `json:"permission"`
+	Allowed    bool   `json:"allowed"`
+	Outcome    string `json:"outcome"`
+	Error      string `json:"error,omitempty"`
+}
+
+// CheckPermissions evaluates a batch of permissions against the caller
+// Each permission may be a requirement expression, see permission.Requirement
+func (h *Handlers) CheckPermissions(w http.ResponseWriter, r *http.Request) {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		auth.WriteError(w, r, auth.NewAuthError(auth.ErrMissingToken, "request not authenticated", http.StatusUnauthorized))
+		return
+	}
+
+	var req CheckPermissionsRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		auth.WriteError(w, r, auth.NewAuthError(auth.ErrInvalidRequest, "invalid request body", http.StatusBadRequest))
+		return
+	}
+	if len(req.Permissions) == 0 || len(req.Permissions) > MaxPermissionChecks {
+		auth.WriteError(w, r, auth.NewAuthError(auth.ErrInvalidRequest, fmt.Sprintf("between 1 and %d permissions are required", MaxPermissionChecks), http.StatusBadRequest))
+		return
+	}
+
+	permissions := h.parser.PrincipalPermissions(principal, req.Account)
+
+	response := CheckPermissionsResponse{Results: make([]PermissionCheckResult, 0, len(req.Permissions))}
+	for _, permStr := range req.Permissions {
+		response.Results = append(response.Results, h.checkPermission(permStr, permissions))
+	}
+
+	h.writeJSON(w, http.StatusOK, response)
+}
+
+// checkPermission evaluates one permission or requirement expression
+func (h *Handlers) checkPermission(permStr string, permissions *permission.Set) PermissionCheckResult {
+	result := PermissionCheckResult{Permission: permStr}
+
+	required, err := h.parser.ParseRequirement(permStr)
+	if err != nil {
+		result.Outcome = "invalid"
+		result.Error = err.Error()
+		return result
+	}
+
+	switch err := required.Check(permissions); {
+	case err == nil:
+		result.Allowed = true
+		result.Outcome = permission.OutcomeGranted
+	case errors.Is(err, auth.ErrPermissionDenied):
+		result.Outcome = permission.OutcomeDenied
+	default:
+		result.Outcome = permission.OutcomeInsufficient
+	}
+	return result
+}
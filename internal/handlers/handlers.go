@@ -8,16 +8,19 @@ import (
 	"time"
 
 	"github.com/jcsawyer123/simple-go-api/internal/auth"
+	"github.com/jcsawyer123/simple-go-api/internal/auth/permission"
 )
 
 type Handlers struct {
 	auth    auth.Service
-	bufPool *sync.Pool // buffer pool for JSON encoding
+	parser  permission.Parser // parses permissions the caller asks about
+	bufPool *sync.Pool        // buffer pool for JSON encoding
 }
 
-func New(auth auth.Service) *Handlers {
+func New(auth auth.Service, parser permission.Parser) *Handlers {
 	return &Handlers{
-		auth: auth,
+		auth:   auth,
+		parser: parser,
 		bufPool: &sync.Pool{
 			New: func() interface{} {
 				return new(bytes.Buffer)
@@ -1,39 +1,117 @@
 package server
 
 import (
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/jcsawyer123/simple-go-api/internal/auth"
+	"github.com/jcsawyer123/simple-go-api/internal/auth/policy"
 )
 
 type Middleware struct {
-	auth auth.Middleware
+	auth             auth.Middleware
+	providers        map[string]auth.Provider
+	queryTokenParams []string
 }
 
-// NewMiddleware creates a server middleware with the provided auth middleware
-func NewMiddleware(authMiddleware auth.Middleware) *Middleware {
+// NewMiddleware creates a server middleware authenticating with a chain of the named providers
+// providers are every auth provider available to route groups, see Using
+// queryTokenParams are the query parameters that may carry tokens
+func NewMiddleware(providers map[string]auth.Provider, defaultChain []string, queryTokenParams []string) (*Middleware, error) {
+	m := &Middleware{
+		providers:        providers,
+		queryTokenParams: queryTokenParams,
+	}
+
+	chain, err := m.chain(defaultChain)
+	if err != nil {
+		return nil, err
+	}
+	m.auth = chain
+
+	return m, nil
+}
+
+// Using returns middleware authenticating with a chain of the named providers, in order
+// Permission checks must use the same value as authentication, so a route group
+// should call Using once and share the result.
+func (m *Middleware) Using(names ...string) (*Middleware, error) {
+	chain, err := m.chain(names)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Middleware{
-		auth: authMiddleware,
+		auth:             chain,
+		providers:        m.providers,
+		queryTokenParams: m.queryTokenParams,
+	}, nil
+}
+
+// chain builds an auth chain from provider names
+func (m *Middleware) chain(names []string) (*auth.Chain, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("auth chain needs at least one provider")
+	}
+
+	links := make([]auth.ChainLink, 0, len(names))
+	for _, name := range names {
+		provider, ok := m.providers[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown auth provider %q", name)
+		}
+		links = append(links, auth.ChainLink{Name: name, Provider: provider})
 	}
+	return auth.NewChain(links...), nil
 }
 
 // SetupGlobal sets up global middleware for the router
-func (m *Middleware) SetupGlobal(r chi.Mux) {
+func (m *Middleware) SetupGlobal(r *chi.Mux) {
 	r.Use(middleware.RealIP)
 	r.Use(middleware.RequestID)
+	// Strip query tokens before the logger sees the URL
+	r.Use(auth.RedactQueryTokens(m.queryTokenParams...))
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Timeout(30 * time.Second))
 	r.Use(middleware.Logger)
 }
 
+// QueryTokens returns extractors for the query parameters redacted by SetupGlobal
+// FromQuery only sees redacted parameters, so query token routes must use these
+// rather than naming a parameter themselves.
+func (m *Middleware) QueryTokens() ([]auth.TokenExtractor, error) {
+	if len(m.queryTokenParams) == 0 {
+		return nil, fmt.Errorf("no query token parameters configured")
+	}
+
+	extractors := make([]auth.TokenExtractor, 0, len(m.queryTokenParams))
+	for _, name := range m.queryTokenParams {
+		extractors = append(extractors, auth.FromQuery(name))
+	}
+	return extractors, nil
+}
+
 // Helper methods to access specific middleware
 func (m *Middleware) Authenticate() func(http.Handler) http.Handler {
 	return m.auth.Authenticate
 }
 
+func (m *Middleware) AuthenticateWith(extractors ...auth.TokenExtractor) func(http.Handler) http.Handler {
+	return m.auth.AuthenticateWith(extractors...)
+}
+
 func (m *Middleware) RequirePermissions(perm string) func(http.Handler) http.Handler {
 	return m.auth.RequirePermissions(perm)
 }
+
+// Enforce enforces route policies using the permission checks of the auth chain
+func (m *Middleware) Enforce(policies *policy.Set, router *chi.Mux) func(http.Handler) http.Handler {
+	return policies.Middleware(router, m.auth)
+}
+
+func (m *Middleware) RequireAccountPermissions(perm string, resolve auth.AccountResolver) func(http.Handler) http.Handler {
+	return m.auth.RequireAccountPermissions(perm, resolve)
+}
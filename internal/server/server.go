@@ -3,14 +3,24 @@ package server
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net/http"
+	"os"
 	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/jcsawyer123/simple-go-api/internal/audit"
 	"github.com/jcsawyer123/simple-go-api/internal/auth"
 	"github.com/jcsawyer123/simple-go-api/internal/auth/aims"
+	"github.com/jcsawyer123/simple-go-api/internal/auth/apikey"
+	"github.com/jcsawyer123/simple-go-api/internal/auth/introspection"
+	"github.com/jcsawyer123/simple-go-api/internal/auth/jwt"
+	"github.com/jcsawyer123/simple-go-api/internal/auth/mtls"
+	"github.com/jcsawyer123/simple-go-api/internal/auth/permission"
+	"github.com/jcsawyer123/simple-go-api/internal/auth/policy"
 	"github.com/jcsawyer123/simple-go-api/internal/config"
 	"github.com/jcsawyer123/simple-go-api/internal/handlers"
 	"github.com/jcsawyer123/simple-go-api/internal/logger"
@@ -19,6 +29,9 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
+// apiPrefix is the route prefix under which every route must have a permission policy
+const apiPrefix = "/api"
+
 type Server struct {
 	httpServer     *http.Server
 	router         *chi.Mux
@@ -27,6 +40,8 @@ type Server struct {
 	handlers       *handlers.Handlers
 	bufPool        *sync.Pool
 	metricsHandler http.Handler
+	apiKeys        *apikey.KeyStore
+	policies       *policy.Set
 }
 
 func New(cfg *config.Config) (*Server, error) {
@@ -48,25 +63,95 @@ func New(cfg *config.Config) (*Server, error) {
 		metricsHandler = promhttp.Handler()
 	}
 
+	// Initialize the authorization audit log
+	if err := setupAudit(cfg.Audit); err != nil {
+		return nil, fmt.Errorf("setting up audit log: %w", err)
+	}
+
+	// Parsed permissions and requirements, shared by every auth provider, route policy and handler
+	parser := permission.Parser{MaxSections: cfg.Auth.PermissionMaxSections}
+	perms := permission.NewCache(parser, cfg.Auth.ParsedPermissionCacheSize)
+
 	// Setup Auth Client
-	authClient, err := aims.NewClient(cfg.AuthServiceURL)
+	authClient, err := aims.NewClient(cfg.AuthServiceURL, perms, aims.WithConfig(authServiceConfig(cfg.Auth)))
 	if err != nil {
 		return nil, fmt.Errorf("creating auth client: %w", err)
 	}
-	// Get the auth middleware
-	authMiddleware, err := auth.NewMiddleware(authClient)
+	// Initialize the router
+	router := chi.NewRouter()
+
+	// Create the auth middleware, reading tokens from the configured sources
+	extractors, err := auth.ParseTokenExtractors(cfg.Auth.TokenSources)
 	if err != nil {
-		log.Fatalf("Failed to create auth middleware: %v", err)
+		return nil, fmt.Errorf("parsing auth token sources: %w", err)
 	}
+	authMiddleware := aims.NewMiddleware(authClient, aims.WithTokenExtractors(extractors...))
 
-	// Initialize the router
-	router := chi.NewRouter()
+	// Auth providers available to route groups, by name
+	providers := map[string]auth.Provider{
+		aims.AuthMethod: authMiddleware,
+	}
+
+	// Static API keys for internal batch jobs
+	var apiKeys *apikey.KeyStore
+	if cfg.Auth.APIKeysFile != "" {
+		apiKeys, err = apikey.NewKeyStore(cfg.Auth.APIKeysFile, parser)
+		if err != nil {
+			return nil, fmt.Errorf("loading API keys: %w", err)
+		}
+		apiKeys.Watch(cfg.Auth.APIKeysReloadInterval)
+
+		keyExtractors, err := auth.ParseTokenExtractors(cfg.Auth.APIKeySources)
+		if err != nil {
+			return nil, fmt.Errorf("parsing API key sources: %w", err)
+		}
+		providers[apikey.AuthMethod] = apikey.NewMiddleware(apikey.NewService(apiKeys, perms), auth.WithTokenExtractors(keyExtractors...))
+		logger.Info().Msgf("Loaded %d API keys", apiKeys.Len())
+	}
+
+	// Signed JWTs verified locally against a JWKS
+	if jwtProvider, err := newJWTProvider(cfg.Auth, perms); err != nil {
+		return nil, err
+	} else if jwtProvider != nil {
+		providers[jwt.AuthMethod] = jwtProvider
+	}
+
+	// Opaque OAuth2 access tokens from partner integrations
+	if introspectionProvider, err := newIntrospectionProvider(cfg.Auth, perms); err != nil {
+		return nil, err
+	} else if introspectionProvider != nil {
+		providers[introspection.AuthMethod] = introspectionProvider
+	}
+
+	// Client certificates for service-to-service traffic
+	if cfg.Auth.MTLSIdentitiesFile != "" {
+		if cfg.TLS.ClientCAFile == "" {
+			return nil, fmt.Errorf("mTLS identities configured without a TLS client CA bundle")
+		}
+
+		identities, err := mtls.LoadIdentityMap(cfg.Auth.MTLSIdentitiesFile, parser)
+		if err != nil {
+			return nil, err
+		}
+		providers[mtls.AuthMethod] = mtls.NewMiddleware(mtls.NewService(identities, perms))
+	}
+
+	// Route permission policies, checked against the routes once they are registered
+	policies, err := policy.Load(cfg.Auth.PolicyFile, parser)
+	if err != nil {
+		return nil, fmt.Errorf("loading route policies: %w", err)
+	}
 
-	// Get the auth middleware from the client
-	authMiddleware := authClient.CreateMiddleware()
+	tlsConfig, err := newTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("configuring TLS: %w", err)
+	}
 
 	// Create middleware manager
-	middleware := NewMiddleware(authMiddleware)
+	middleware, err := NewMiddleware(providers, cfg.Auth.Providers, cfg.Auth.QueryTokenParams)
+	if err != nil {
+		return nil, fmt.Errorf("creating auth chain: %w", err)
+	}
 
 	// Initialize server
 	srv := &Server{
@@ -74,14 +159,23 @@ func New(cfg *config.Config) (*Server, error) {
 		auth:           authClient,
 		middleware:     middleware,
 		bufPool:        bufPool,
-		handlers:       handlers.New(authClient),
+		handlers:       handlers.New(authClient, parser),
 		metricsHandler: metricsHandler,
+		apiKeys:        apiKeys,
+		policies:       policies,
 	}
 	logger.Info().Msg("Server initialized")
 
 	// Setup middleware and routes
 	srv.setupMiddleware(cfg)
-	srv.setupRoutes(cfg)
+	if err := srv.setupRoutes(cfg); err != nil {
+		return nil, fmt.Errorf("setting up routes: %w", err)
+	}
+
+	// Fail fast on unprotected API routes and policies for routes that don't exist
+	if err := policies.Validate(router, apiPrefix); err != nil {
+		return nil, fmt.Errorf("validating route policies: %w", err)
+	}
 
 	logger.Info().Msg("Server started")
 
@@ -94,11 +188,150 @@ func New(cfg *config.Config) (*Server, error) {
 		IdleTimeout:       120 * time.Second,
 		ReadHeaderTimeout: 2 * time.Second,
 		MaxHeaderBytes:    1 << 20,
+		TLSConfig:         tlsConfig,
 	}
 
 	return srv, nil
 }
 
+// authServiceConfig converts the auth configuration into auth service settings
+func authServiceConfig(cfg config.AuthConfig) auth.BaseServiceConfig {
+	return auth.BaseServiceConfig{
+		Timeout:                        cfg.Timeout,
+		RetryCount:                     cfg.RetryCount,
+		RetryWaitTime:                  cfg.RetryWaitTime,
+		RetryMaxWaitTime:               cfg.RetryMaxWaitTime,
+		CacheTTL:                       cfg.CacheTTL,
+		CacheStaleGrace:                cfg.CacheStaleGrace,
+		CacheKeySecret:                 cfg.CacheKeySecret,
+		RejectedCacheTTL:               cfg.RejectedCacheTTL,
+		RejectedCacheMaxEntries:        cfg.RejectedCacheMaxEntries,
+		CircuitBreakerMaxRequests:      cfg.CircuitBreakerMaxRequests,
+		CircuitBreakerTimeout:          cfg.CircuitBreakerTimeout,
+		CircuitBreakerMinRequests:      cfg.CircuitBreakerMinRequests,
+		CircuitBreakerFailureThreshold: cfg.CircuitBreakerFailureThreshold,
+	}
+}
+
+// newTLSConfig creates the server TLS configuration, nil if TLS is not configured
+// Client certificates are verified against the client CA bundle when one is set
+func newTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	if cfg.CertFile == "" && cfg.KeyFile == "" {
+		if cfg.ClientCAFile != "" {
+			return nil, fmt.Errorf("client CA bundle requires a server certificate")
+		}
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading server certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading client CA bundle: %w", err)
+		}
+
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA bundle %s", cfg.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = clientCAs
+
+		switch cfg.ClientAuth {
+		case "verify-if-given":
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		case "require":
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		default:
+			return nil, fmt.Errorf("unknown client auth policy %q", cfg.ClientAuth)
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// newJWTProvider creates the JWT auth provider, nil if no JWKS is configured
+func newJWTProvider(cfg config.AuthConfig, perms *permission.Cache) (auth.Provider, error) {
+	var source jwt.KeySource
+	switch {
+	case cfg.JWT.JWKSURL != "":
+		source = jwt.URLSource(cfg.JWT.JWKSURL, cfg.Timeout)
+	case cfg.JWT.JWKSFile != "":
+		source = jwt.FileSource(cfg.JWT.JWKSFile)
+	default:
+		return nil, nil
+	}
+
+	extractors, err := auth.ParseTokenExtractors(cfg.JWT.TokenSources)
+	if err != nil {
+		return nil, fmt.Errorf("parsing JWT sources: %w", err)
+	}
+
+	keys := jwt.NewKeySet(source, cfg.JWT.JWKSRefreshInterval, cfg.JWT.JWKSMinRefreshInterval)
+	service := jwt.NewService(keys, jwt.Config{
+		Issuer:           cfg.JWT.Issuer,
+		Audience:         cfg.JWT.Audience,
+		PermissionsClaim: cfg.JWT.PermissionsClaim,
+		AccountClaim:     cfg.JWT.AccountClaim,
+		Leeway:           cfg.JWT.Leeway,
+	}, perms)
+	return jwt.NewMiddleware(service, auth.WithTokenExtractors(extractors...)), nil
+}
+
+// newIntrospectionProvider creates the OAuth2 introspection auth provider, nil if no endpoint is configured
+func newIntrospectionProvider(cfg config.AuthConfig, perms *permission.Cache) (auth.Provider, error) {
+	if cfg.Introspection.URL == "" {
+		return nil, nil
+	}
+
+	// Unmapped scopes grant nothing, so introspection is useless without a scope map
+	if cfg.Introspection.ScopeMapFile == "" {
+		return nil, fmt.Errorf("AUTH_INTROSPECTION_SCOPE_MAP is required when AUTH_INTROSPECTION_URL is set")
+	}
+	scopes, err := introspection.LoadScopeMap(cfg.Introspection.ScopeMapFile, perms.Parser())
+	if err != nil {
+		return nil, err
+	}
+
+	extractors, err := auth.ParseTokenExtractors(cfg.Introspection.TokenSources)
+	if err != nil {
+		return nil, fmt.Errorf("parsing introspection token sources: %w", err)
+	}
+
+	client, err := introspection.NewClient(cfg.Introspection.URL, cfg.Introspection.ClientID, cfg.Introspection.ClientSecret, perms,
+		introspection.WithConfig(authServiceConfig(cfg)),
+		introspection.WithScopeMap(scopes),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating introspection client: %w", err)
+	}
+	return introspection.NewMiddleware(client, auth.WithTokenExtractors(extractors...)), nil
+}
+
+func setupAudit(cfg config.AuditConfig) error {
+	if cfg.File == "" {
+		audit.InitGlobal(audit.NullSink{})
+		return nil
+	}
+
+	sink, err := audit.NewFileSink(cfg.File, int64(cfg.MaxSizeMB)<<20, cfg.MaxBackups)
+	if err != nil {
+		return err
+	}
+
+	audit.InitGlobal(audit.NewAsyncSink(sink, cfg.BufferSize))
+	logger.Info().Msgf("Authorization decisions audited to %s", cfg.File)
+	return nil
+}
+
 func setupMetrics(cfg config.MetricsConfig) error {
 	if !cfg.Enabled {
 		// Use a null provider if metrics are disabled
@@ -143,7 +376,7 @@ func setupMetrics(cfg config.MetricsConfig) error {
 }
 
 func (s *Server) setupMiddleware(cfg *config.Config) {
-	s.middleware.SetupGlobal(*s.router)
+	s.middleware.SetupGlobal(s.router)
 
 	// Add metrics middleware if metrics are enabled
 	if cfg.Metrics.Enabled {
@@ -151,7 +384,7 @@ func (s *Server) setupMiddleware(cfg *config.Config) {
 	}
 }
 
-func (s *Server) setupRoutes(cfg *config.Config) {
+func (s *Server) setupRoutes(cfg *config.Config) error {
 	// Add Prometheus metrics endpoint if enabled
 	if cfg.Metrics.Enabled && cfg.Metrics.Prometheus.Enabled && s.metricsHandler != nil {
 		s.router.Handle("/metrics", s.metricsHandler)
@@ -159,29 +392,58 @@ func (s *Server) setupRoutes(cfg *config.Config) {
 	}
 
 	s.router.Get("/health", s.handlers.HealthCheck)
-	s.router.Route("/api", func(r chi.Router) {
-		// Auth middleware for all /api routes
-		r.Use(s.middleware.Authenticate())
 
+	// Signed download links carry an AIMS token in one of the AUTH_QUERY_TOKEN_PARAMS
+	queryTokens, err := s.middleware.QueryTokens()
+	if err != nil {
+		return fmt.Errorf("downloads: %w", err)
+	}
+	downloads, err := s.middleware.Using(aims.AuthMethod)
+	if err != nil {
+		return fmt.Errorf("downloads: %w", err)
+	}
+	s.router.Route("/downloads", func(r chi.Router) {
+		r.Use(downloads.AuthenticateWith(queryTokens...))
 		r.Get("/data", s.handlers.GetData)
+	})
+	s.router.Route(apiPrefix, func(r chi.Router) {
+		// Auth middleware for all /api routes, trying the configured providers in order
+		r.Use(s.middleware.Authenticate())
 
-		// Permission-protected routes
-		r.Route("/perms", func(r chi.Router) {
-			r.Use(s.middleware.RequirePermissions(aims.MyServiceUpdatePerm))
-			r.Get("/test", s.handlers.TestPermissions)
-		})
+		// Permissions for every /api route come from the route policy file
+		r.Use(s.middleware.Enforce(s.policies, s.router))
 
-		// Alternative permission middleware usage - instigator:*:disable:account has explicit deny
-		r.With(s.middleware.RequirePermissions(aims.InstigatorDisableAccountPerm)).
-			Get("/test", s.handlers.TestPermissions)
+		r.Get("/data", s.handlers.GetData)
+		r.Get("/perms/test", s.handlers.TestPermissions)
+		r.Get("/any/test", s.handlers.TestPermissions)
+		r.Get("/test", s.handlers.TestPermissions)
+
+		// Caller identity and permission introspection
+		r.Get("/auth/me", s.handlers.Me)
+		r.Post("/auth/check", s.handlers.CheckPermissions)
+		r.Get("/auth/explain", s.handlers.ExplainPermission)
+
+		// Account-scoped routes
+		r.Route("/accounts/{accountID}", func(r chi.Router) {
+			r.Get("/perms/test", s.handlers.TestPermissions)
+			r.Get("/resources/{resource}/perms/test", s.handlers.TestPermissions)
+		})
 	})
+
+	return nil
 }
 
 func (s *Server) Start(ctx context.Context) error {
 	g, ctx := errgroup.WithContext(ctx)
 
 	g.Go(func() error {
-		if err := s.httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		// Certificates are already loaded into the TLS config
+		serve := s.httpServer.ListenAndServe
+		if s.httpServer.TLSConfig != nil {
+			serve = func() error { return s.httpServer.ListenAndServeTLS("", "") }
+		}
+
+		if err := serve(); err != http.ErrServerClosed {
 			return fmt.Errorf("http server error: %w", err)
 		}
 		return nil
@@ -195,6 +457,18 @@ func (s *Server) Start(ctx context.Context) error {
 		// Close metrics system on shutdown
 		defer metrics.CloseGlobal()
 
+		// Flush the audit log once in-flight requests have finished
+		defer func() {
+			if err := audit.CloseGlobal(); err != nil {
+				logger.Errorf("Closing audit log: %v", err)
+			}
+		}()
+
+		// Stop watching the API key file
+		if s.apiKeys != nil {
+			s.apiKeys.Stop()
+		}
+
 		if err := s.httpServer.Shutdown(shutdownCtx); err != nil {
 			return fmt.Errorf("http server shutdown: %w", err)
 		}